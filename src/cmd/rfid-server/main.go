@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
@@ -17,6 +19,7 @@ import (
 	"rfid/internal/config"
 	"rfid/internal/models"
 	"rfid/internal/routes"
+	"rfid/internal/utils"
 )
 
 func getTimePtr(t time.Time) *time.Time {
@@ -30,6 +33,14 @@ func getUintPtr(u uint) *uint {
 func main() {
 	appConfig := config.Load()
 
+	if _, err := utils.DefaultLocation(); err != nil {
+		log.Fatalf("Ismeretlen alapértelmezett időzóna (%s): %v", appConfig.DefaultTimezone, err)
+	}
+
+	if len(appConfig.AccessListSigningKey) < utils.MinAccessListSigningKeyLength {
+		log.Fatalf("Az ACCESS_LIST_SIGNING_KEY megadása kötelező, legalább %d karakter hosszan", utils.MinAccessListSigningKeyLength)
+	}
+
 	db, err := setupDatabase(appConfig)
 	if err != nil {
 		log.Fatalf("Adatbázis kapcsolódás sikertelen: %v", err)
@@ -62,14 +73,40 @@ func setupDatabase(config *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&models.User{}, &models.Card{}, &models.Room{}, &models.Permission{}, &models.Log{}, &models.Group{}); err != nil {
+	if err := db.SetupJoinTable(&models.Group{}, "Rooms", &models.GroupRoom{}); err != nil {
+		return nil, fmt.Errorf("csoport-helyiség kapcsolótábla beállítása sikertelen: %w", err)
+	}
+
+	if err := db.AutoMigrate(&models.User{}, &models.Card{}, &models.Room{}, &models.Permission{}, &models.Log{}, &models.Group{}, &models.GroupRoom{}, &models.RoomOccupancy{}, &models.Device{}, &models.APIKey{}, &models.DeviceAccessList{}, &models.EmergencyState{}, &models.CalendarException{}, &models.Building{}, &models.Visit{}, &models.Role{}, &models.AccessRequest{}, &models.RecertificationCampaign{}, &models.RecertificationItem{}); err != nil {
 		return nil, fmt.Errorf("adatbázis migráció sikertelen: %w", err)
 	}
 
+	if err := migrateRoomOpeningHours(db); err != nil {
+		return nil, fmt.Errorf("nyitvatartások migrálása sikertelen: %w", err)
+	}
+
 	if err := createInitialData(db); err != nil {
 		return nil, fmt.Errorf("kezdeti adatok létrehozása sikertelen: %w", err)
 	}
 
+	if err := ensureSystemRoles(db); err != nil {
+		return nil, fmt.Errorf("rendszer szerepkörök létrehozása sikertelen: %w", err)
+	}
+
+	if err := utils.LinkRoomBuildings(db); err != nil {
+		return nil, fmt.Errorf("helyiségek épülethez rendelése sikertelen: %w", err)
+	}
+
+	if err := importLegacyAPIKeys(db, config.LegacyAPIKeys); err != nil {
+		return nil, fmt.Errorf("API_KEYS kulcsok importálása sikertelen: %w", err)
+	}
+
+	if config.APIKeyRequired {
+		if err := ensureBootstrapAPIKey(db, config.BootstrapKeyFile); err != nil {
+			return nil, fmt.Errorf("kezdeti API kulcs létrehozása sikertelen: %w", err)
+		}
+	}
+
 	return db, nil
 }
 
@@ -191,8 +228,8 @@ func createInitialData(db *gorm.DB) error {
 				Building:       "Főépület",
 				RoomNumber:     "101",
 				AccessLevel:    models.AccessLevelPublic,
-				OperatingHours: "07:00-20:00",
-				OperatingDays:  "1,2,3,4,5",
+				OpeningHours:   "mon-fri 07:00-20:00",
+				IsMainEntrance: true,
 			},
 			{
 				Name:           "Informatikai Labor",
@@ -200,8 +237,7 @@ func createInitialData(db *gorm.DB) error {
 				Building:       "Informatikai Épület",
 				RoomNumber:     "I-203",
 				AccessLevel:    models.AccessLevelRestricted,
-				OperatingHours: "08:00-18:00",
-				OperatingDays:  "1,2,3,4,5",
+				OpeningHours:   "mon-fri 08:00-18:00",
 			},
 			{
 				Name:           "Könyvtár",
@@ -209,8 +245,7 @@ func createInitialData(db *gorm.DB) error {
 				Building:       "Központi Épület",
 				RoomNumber:     "K-002",
 				AccessLevel:    models.AccessLevelPublic,
-				OperatingHours: "08:00-20:00",
-				OperatingDays:  "1,2,3,4,5,6",
+				OpeningHours:   "mon-sat 08:00-20:00",
 			},
 			{
 				Name:           "Tanulmányi Osztály",
@@ -218,8 +253,7 @@ func createInitialData(db *gorm.DB) error {
 				Building:       "Főépület",
 				RoomNumber:     "F-112",
 				AccessLevel:    models.AccessLevelPublic,
-				OperatingHours: "09:00-16:00",
-				OperatingDays:  "1,2,3,4,5",
+				OpeningHours:   "mon-fri 09:00-16:00",
 			},
 			{
 				Name:           "Szerver szoba",
@@ -227,8 +261,7 @@ func createInitialData(db *gorm.DB) error {
 				Building:       "Informatikai Épület",
 				RoomNumber:     "I-001",
 				AccessLevel:    models.AccessLevelAdmin,
-				OperatingHours: "00:00-23:59",
-				OperatingDays:  "0,1,2,3,4,5,6",
+				OpeningHours:   "daily 00:00-24:00",
 			},
 			{
 				Name:           "Kutatólabor",
@@ -236,8 +269,7 @@ func createInitialData(db *gorm.DB) error {
 				Building:       "Kutatási Épület",
 				RoomNumber:     "K-101",
 				AccessLevel:    models.AccessLevelRestricted,
-				OperatingHours: "00:00-23:59",
-				OperatingDays:  "0,1,2,3,4,5,6",
+				OpeningHours:   "daily 00:00-24:00",
 			},
 			{
 				Name:           "Előadóterem",
@@ -245,8 +277,7 @@ func createInitialData(db *gorm.DB) error {
 				Building:       "Főépület",
 				RoomNumber:     "F-201",
 				AccessLevel:    models.AccessLevelPublic,
-				OperatingHours: "08:00-20:00",
-				OperatingDays:  "1,2,3,4,5",
+				OpeningHours:   "mon-fri 08:00-20:00",
 			},
 		}
 
@@ -396,6 +427,18 @@ func createInitialData(db *gorm.DB) error {
 				ExpiryDate: getTimePtr(now.AddDate(1, 0, 0)),
 				Status:     models.CardStatusActive,
 			},
+			{
+				CardID:     "VISITOR001",
+				IssueDate:  now,
+				Status:     models.CardStatusPending,
+				Pooled:     true,
+			},
+			{
+				CardID:     "VISITOR002",
+				IssueDate:  now,
+				Status:     models.CardStatusPending,
+				Pooled:     true,
+			},
 		}
 
 		for _, card := range cards {
@@ -727,6 +770,189 @@ func createInitialData(db *gorm.DB) error {
 	return nil
 }
 
+// migrateRoomOpeningHours converts the former operating_hours and
+// operating_days columns into opening_hours and drops them.
+func migrateRoomOpeningHours(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&models.Room{}, "operating_hours") || !migrator.HasColumn(&models.Room{}, "operating_days") {
+		return nil
+	}
+
+	var legacy []struct {
+		ID             uint
+		Name           string
+		OpeningHours   string
+		OperatingHours string
+		OperatingDays  string
+	}
+	if err := db.Table("rooms").Select("id, name, opening_hours, operating_hours, operating_days").Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	for _, room := range legacy {
+		if room.OpeningHours != "" {
+			continue
+		}
+
+		openingHours, err := models.LegacyOpeningHours(room.OperatingHours, room.OperatingDays)
+		if err != nil {
+			log.Printf("Figyelmeztetés: %s helyiség (#%d) nyitvatartása nem értelmezhető (%v), a nyitvatartási napokon egész nap nyitva marad: %s",
+				room.Name, room.ID, err, openingHours)
+		}
+		if openingHours == "" {
+			continue
+		}
+
+		if err := db.Table("rooms").Where("id = ?", room.ID).Update("opening_hours", openingHours).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := migrator.DropColumn(&models.Room{}, "operating_hours"); err != nil {
+		return err
+	}
+	return migrator.DropColumn(&models.Room{}, "operating_days")
+}
+
+// ensureSystemRoles creates the missing default roles. Existing roles are
+// left alone, their privileges may have been edited.
+func ensureSystemRoles(db *gorm.DB) error {
+	roles := []models.Role{
+		{
+			Name:        "security_officer",
+			Description: "Biztonsági szolgálat: naplók, ajtóvezérlés és vészhelyzetek",
+			Privileges: []models.Privilege{
+				models.PrivilegeLogsRead,
+				models.PrivilegeDoorsControl,
+				models.PrivilegeEmergenciesRead,
+				models.PrivilegeEmergenciesManage,
+				models.PrivilegeRoomsRead,
+				models.PrivilegeCardsRead,
+				models.PrivilegeUsersRead,
+				models.PrivilegeDevicesRead,
+				models.PrivilegeAccessExplain,
+			},
+		},
+		{
+			Name:        "helpdesk",
+			Description: "Portaszolgálat: kártyák letiltása és feloldása",
+			Privileges: []models.Privilege{
+				models.PrivilegeUsersRead,
+				models.PrivilegeCardsRead,
+				models.PrivilegeCardsBlock,
+				models.PrivilegeVisitsHost,
+			},
+		},
+		{
+			Name:        "building_manager",
+			Description: "Épületgondnok: helyiségek, jogosultságok és naptár kezelése",
+			Privileges: []models.Privilege{
+				models.PrivilegeRoomsRead,
+				models.PrivilegeRoomsWrite,
+				models.PrivilegeDoorsControl,
+				models.PrivilegeBuildingsRead,
+				models.PrivilegePermissionsRead,
+				models.PrivilegePermissionsWrite,
+				models.PrivilegeCalendarRead,
+				models.PrivilegeCalendarWrite,
+				models.PrivilegeLogsRead,
+				models.PrivilegeRecertificationsRead,
+				models.PrivilegeRecertificationsManage,
+			},
+		},
+		{
+			Name:        "auditor",
+			Description: "Auditor: csak olvasási jog",
+		},
+	}
+
+	for _, privilege := range models.AllPrivileges {
+		if strings.HasSuffix(string(privilege), ".read") {
+			roles[3].Privileges = append(roles[3].Privileges, privilege)
+		}
+	}
+
+	for _, role := range roles {
+		role.System = true
+		if err := db.Where("name = ?", role.Name).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importLegacyAPIKeys stores the keys of the former API_KEYS variable as
+// hashed integration keys, so integrations using them keep working after
+// the upgrade. Keys imported on an earlier start, or revoked since, are
+// left as they are.
+func importLegacyAPIKeys(db *gorm.DB, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	apiKeys := utils.NewAPIKeyService(db)
+	imported := 0
+	for i, plain := range keys {
+		key, err := apiKeys.Import(fmt.Sprintf("API_KEYS #%d", i+1), plain)
+		if err != nil {
+			return err
+		}
+		if key != nil {
+			imported++
+		}
+	}
+
+	log.Printf("Figyelmeztetés: az API_KEYS változó elavult. %d új kulcs importálva integrációs kulcsként, a kulcsokat az /api/api-keys végponton kezelje, és távolítsa el a változót.", imported)
+	return nil
+}
+
+// ensureBootstrapAPIKey issues a single integration key when no usable key
+// exists, otherwise the API key management endpoints could not be reached.
+// The key is written to keyFile, readable by the owner only, and never
+// logged.
+func ensureBootstrapAPIKey(db *gorm.DB, keyFile string) error {
+	var activeCount int64
+	if err := db.Model(&models.APIKey{}).
+		Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now()).
+		Count(&activeCount).Error; err != nil {
+		return err
+	}
+
+	if activeCount > 0 {
+		return nil
+	}
+
+	// Without the file nobody could learn the key, so it is only kept when
+	// the file was written.
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, plain, err := utils.NewAPIKeyService(tx).Create("bootstrap", nil, 0, nil)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if err := file.Chmod(0600); err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := fmt.Fprintln(file, plain); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Kezdeti API kulcs létrehozva, a kulcs a(z) %s fájlban található. Cserélje le az /api/api-keys végponton, majd törölje a fájlt.", keyFile)
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
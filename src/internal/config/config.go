@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,13 +16,22 @@ type Config struct {
 	EnableRESTAPI   bool
 	EnableWebsocket bool
 
-	APIKeyRequired bool
-	APIKeys        []string
+	APIKeyRequired   bool
+	BootstrapKeyFile string
+	// LegacyAPIKeys are the keys of the former API_KEYS variable, imported
+	// as integration keys at startup.
+	LegacyAPIKeys []string
+
+	ReaderOfflineThreshold time.Duration
+
+	DefaultTimezone string
 
 	DBPath string
 
 	JWTSecret     string
 	EncryptionKey string
+
+	AccessListSigningKey string
 }
 
 func Load() *Config {
@@ -35,13 +45,20 @@ func Load() *Config {
 		EnableRESTAPI:   getBoolEnv("ENABLE_REST_API", true),
 		EnableWebsocket: getBoolEnv("ENABLE_WEBSOCKET", false),
 
-		APIKeyRequired: getBoolEnv("API_KEY_REQUIRED", false),
-		APIKeys:        getStringSliceEnv("API_KEYS", []string{}),
+		APIKeyRequired:   getBoolEnv("API_KEY_REQUIRED", false),
+		BootstrapKeyFile: getEnv("BOOTSTRAP_KEY_FILE", "bootstrap-api-key"),
+		LegacyAPIKeys:    getStringSliceEnv("API_KEYS", []string{}),
+
+		ReaderOfflineThreshold: time.Duration(getIntEnv("READER_OFFLINE_THRESHOLD", 90)) * time.Second,
+
+		DefaultTimezone: getEnv("DEFAULT_TIMEZONE", ""),
 
 		DBPath: getEnv("DB_PATH", "rfid.db"),
 
 		JWTSecret:     getEnv("JWT_SECRET", "32-karakter-aes-kulcs-ide12345678"),
 		EncryptionKey: getEnv("ENCRYPTION_KEY", "12345678901234567890123456789012"),
+
+		AccessListSigningKey: os.Getenv("ACCESS_LIST_SIGNING_KEY"),
 	}
 
 	return config
@@ -74,5 +91,24 @@ func getStringSliceEnv(key string, fallback []string) []string {
 		return fallback
 	}
 
-	return strings.Split(value, ",")
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func getIntEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	return intValue
 }
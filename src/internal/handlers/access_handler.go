@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+)
+
+type AccessHandler struct {
+	db            *gorm.DB
+	accessControl *utils.AccessControlService
+}
+
+func NewAccessHandler(db *gorm.DB) *AccessHandler {
+	return &AccessHandler{
+		db:            db,
+		accessControl: utils.NewAccessControlService(db),
+	}
+}
+
+func (h *AccessHandler) ExplainAccess(c *gin.Context) {
+	var input struct {
+		CardID         string                 `json:"card_id" binding:"required"`
+		CredentialType models.CredentialType  `json:"credential_type"`
+		RoomID         uint                   `json:"room_id" binding:"required"`
+		Direction      models.AccessDirection `json:"direction"`
+		At             *time.Time             `json:"at"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a kártya azonosítót és a helyiség azonosítót."})
+		return
+	}
+
+	var room models.Room
+	if err := h.db.First(&room, input.RoomID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Helyiség nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség adatok lekérése sikertelen"})
+		}
+		return
+	}
+
+	if !input.Direction.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen irány. Megengedett értékek: entry, exit"})
+		return
+	}
+
+	if input.CredentialType != "" && !input.CredentialType.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen azonosító típus. Megengedett értékek: card, mobile_nfc, pin"})
+		return
+	}
+
+	req := utils.AccessRequest{
+		CardID:         input.CardID,
+		CredentialType: input.CredentialType,
+		RoomID:         input.RoomID,
+		Direction:      input.Direction,
+		Time:           time.Now(),
+	}
+	if input.At != nil {
+		req.Time = *input.At
+	}
+
+	trace, err := h.accessControl.Explain(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférés kiértékelése sikertelen: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
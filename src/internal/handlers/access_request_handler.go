@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+	"rfid/internal/websocket"
+)
+
+// AccessRequestHandler serves the access request workflow. Requesters work
+// on their own requests under /api/me, approvers on the requests of their
+// rooms under /api/access-requests.
+type AccessRequestHandler struct {
+	db       *gorm.DB
+	requests *utils.AccessRequestService
+}
+
+func NewAccessRequestHandler(db *gorm.DB) *AccessRequestHandler {
+	return &AccessRequestHandler{
+		db:       db,
+		requests: utils.NewAccessRequestService(db),
+	}
+}
+
+func (h *AccessRequestHandler) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	h.requests.SetWebSocketHandler(wsHandler)
+}
+
+func (h *AccessRequestHandler) GetMyAccessRequests(c *gin.Context) {
+	var requests []models.AccessRequest
+
+	query := h.db.Preload("Room").Where("requester_id = ?", currentUserID(c))
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférési kérelmek lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func (h *AccessRequestHandler) CreateAccessRequest(c *gin.Context) {
+	var input utils.AccessRequestInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a helyiséget és az indoklást."})
+		return
+	}
+
+	request, err := h.requests.Create(currentUserID(c), input)
+	switch err {
+	case nil:
+	case utils.ErrAccessRequestRoomMissing, utils.ErrAccessRequestInvalidWindow:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok: " + err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférési kérelem rögzítése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+func (h *AccessRequestHandler) CancelAccessRequest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen kérelem azonosító"})
+		return
+	}
+
+	request, err := h.requests.Cancel(uint(id), currentUserID(c))
+	h.respondDecision(c, request, err, "Hozzáférési kérelem visszavonva")
+}
+
+// GetAccessRequests lists the requests the caller can decide, the pending
+// ones unless a status is given.
+func (h *AccessRequestHandler) GetAccessRequests(c *gin.Context) {
+	var requests []models.AccessRequest
+
+	query := h.requests.Decidable(h.db.Preload("Requester").Preload("Room"), currentUser(c))
+	query = query.Where("status = ?", c.DefaultQuery("status", string(models.AccessRequestPending)))
+
+	if roomID := c.Query("room_id"); roomID != "" {
+		query = query.Where("room_id = ?", roomID)
+	}
+
+	if err := query.Order("created_at").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférési kérelmek lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func (h *AccessRequestHandler) GetAccessRequest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen kérelem azonosító"})
+		return
+	}
+
+	var request models.AccessRequest
+	if err := h.db.Preload("Requester").Preload("Room").First(&request, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hozzáférési kérelem nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférési kérelem lekérése sikertelen"})
+		}
+		return
+	}
+
+	if request.RequesterID != currentUserID(c) {
+		allowed, err := h.requests.CanDecide(currentUser(c), request.Room)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférési kérelem lekérése sikertelen"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hozzáférési kérelem nem található"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+func (h *AccessRequestHandler) ApproveAccessRequest(c *gin.Context) {
+	id, note, ok := h.bindDecision(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.requests.Approve(id, currentUser(c), note)
+	h.respondDecision(c, request, err, "Hozzáférési kérelem jóváhagyva")
+}
+
+func (h *AccessRequestHandler) RejectAccessRequest(c *gin.Context) {
+	id, note, ok := h.bindDecision(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.requests.Reject(id, currentUser(c), note)
+	h.respondDecision(c, request, err, "Hozzáférési kérelem elutasítva")
+}
+
+func (h *AccessRequestHandler) bindDecision(c *gin.Context) (uint, string, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen kérelem azonosító"})
+		return 0, "", false
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok"})
+			return 0, "", false
+		}
+	}
+
+	return uint(id), input.Note, true
+}
+
+func (h *AccessRequestHandler) respondDecision(c *gin.Context, request *models.AccessRequest, err error, message string) {
+	switch err {
+	case nil:
+	case gorm.ErrRecordNotFound, utils.ErrNotApprover:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hozzáférési kérelem nem található"})
+		return
+	case utils.ErrAccessRequestNotPending:
+		c.JSON(http.StatusConflict, gin.H{"error": "A kérelemről már döntöttek"})
+		return
+	case utils.ErrAccessRequestOwn:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Saját kérelemről nem lehet dönteni"})
+		return
+	case utils.ErrAccessRequestInvalidWindow:
+		c.JSON(http.StatusConflict, gin.H{"error": "A kért időszak már lejárt"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférési kérelem feldolgozása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "request": request})
+}
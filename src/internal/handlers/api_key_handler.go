@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+)
+
+type APIKeyHandler struct {
+	db      *gorm.DB
+	apiKeys *utils.APIKeyService
+}
+
+func NewAPIKeyHandler(db *gorm.DB) *APIKeyHandler {
+	return &APIKeyHandler{
+		db:      db,
+		apiKeys: utils.NewAPIKeyService(db),
+	}
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+
+	query := h.db.Preload("Device")
+
+	if deviceID := c.Query("device_id"); deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+
+	if c.Query("active") == "true" {
+		query = query.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+
+	if err := query.Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kulcsok lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
+	key, ok := h.findAPIKey(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var input struct {
+		Name      string     `json:"name" binding:"required"`
+		DeviceID  *uint      `json:"device_id"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a kulcs nevét."})
+		return
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A lejárati időnek a jövőben kell lennie"})
+		return
+	}
+
+	if input.DeviceID != nil {
+		var device models.Device
+		if err := h.db.First(&device, *input.DeviceID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A megadott olvasó nem létezik"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó lekérése sikertelen"})
+			}
+			return
+		}
+	}
+
+	key, plain, err := h.apiKeys.Create(input.Name, input.DeviceID, currentUserID(c), input.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kulcs létrehozása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API kulcs létrehozva. A kulcs csak most jelenik meg, kérjük, mentse el.",
+		"key":     plain,
+		"api_key": key,
+	})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	key, ok := h.findAPIKey(c)
+	if !ok {
+		return
+	}
+
+	if key.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Az API kulcs már vissza van vonva"})
+		return
+	}
+
+	if err := h.apiKeys.Revoke(key, currentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kulcs visszavonása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API kulcs sikeresen visszavonva", "api_key": key})
+}
+
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	key, ok := h.findAPIKey(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ExpiresAt    *time.Time `json:"expires_at"`
+		GraceMinutes int        `json:"grace_minutes"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !key.IsUsableAt(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Visszavont vagy lejárt API kulcs nem cserélhető"})
+		return
+	}
+
+	if input.GraceMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A türelmi idő nem lehet negatív"})
+		return
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A lejárati időnek a jövőben kell lennie"})
+		return
+	}
+
+	newKey, plain, err := h.apiKeys.Rotate(key, currentUserID(c), input.ExpiresAt, time.Duration(input.GraceMinutes)*time.Minute)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API kulcs cseréje sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "API kulcs sikeresen lecserélve. Az új kulcs csak most jelenik meg, kérjük, mentse el.",
+		"key":         plain,
+		"api_key":     newKey,
+		"replaced_id": key.ID,
+	})
+}
+
+func (h *APIKeyHandler) findAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen API kulcs azonosító"})
+		return nil, false
+	}
+
+	var key models.APIKey
+	if err := h.db.Preload("Device").First(&key, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API kulcs nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "API kulcs lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	return &key, true
+}
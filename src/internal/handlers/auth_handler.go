@@ -71,7 +71,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := h.db.Where("username = ?", input.Username).First(&user).Error; err != nil {
+	if err := h.db.Preload("Roles").Preload("Buildings").Where("username = ?", input.Username).First(&user).Error; err != nil {
 		h.recordLoginAttempt(input.Username, ipAddress, false)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Érvénytelen felhasználónév vagy jelszó"})
 		return
@@ -83,6 +83,12 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if user.IsVisitor {
+		h.recordLoginAttempt(input.Username, ipAddress, false)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Látogatói fiókkal nem lehet bejelentkezni"})
+		return
+	}
+
 	if !user.CheckPassword(input.Password) {
 		h.recordLoginAttempt(input.Username, ipAddress, false)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Érvénytelen felhasználónév vagy jelszó"})
@@ -100,12 +106,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"token": token,
 		"user": gin.H{
-			"id":        user.ID,
-			"username":  user.Username,
-			"firstName": user.FirstName,
-			"lastName":  user.LastName,
-			"email":     user.Email,
-			"isAdmin":   user.IsAdmin,
+			"id":         user.ID,
+			"username":   user.Username,
+			"firstName":  user.FirstName,
+			"lastName":   user.LastName,
+			"email":      user.Email,
+			"isAdmin":    user.IsAdmin,
+			"privileges": user.Privileges(),
+			"buildings":  user.Buildings,
 		},
 	})
 }
@@ -136,7 +144,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		FirstName: input.FirstName,
 		LastName:  input.LastName,
 		Email:     input.Email,
-		IsAdmin:   input.IsAdmin,
+		IsAdmin:   input.IsAdmin && canGrantAdmin(c),
 		Active:    true,
 	}
 
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+)
+
+type BuildingHandler struct {
+	db *gorm.DB
+}
+
+func NewBuildingHandler(db *gorm.DB) *BuildingHandler {
+	return &BuildingHandler{
+		db: db,
+	}
+}
+
+func (h *BuildingHandler) GetBuildings(c *gin.Context) {
+	var buildings []models.Building
+
+	query := h.db.Order("name")
+	if scope := currentBuildingScope(c); scope != nil {
+		query = query.Where("id IN ?", scope.BuildingIDs)
+	}
+
+	if err := query.Find(&buildings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Épületek lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildings)
+}
+
+func (h *BuildingHandler) GetBuilding(c *gin.Context) {
+	building, ok := h.findBuilding(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, building)
+}
+
+func (h *BuildingHandler) CreateBuilding(c *gin.Context) {
+	var input struct {
+		Name     string `json:"name" binding:"required"`
+		Timezone string `json:"timezone"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg az épület nevét."})
+		return
+	}
+
+	if currentBuildingScope(c) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Új épületet csak a teljes kampusz kezelője hozhat létre"})
+		return
+	}
+
+	if input.Timezone != "" {
+		if _, err := utils.LoadLocation(input.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ismeretlen időzóna: " + input.Timezone})
+			return
+		}
+	}
+
+	var count int64
+	h.db.Model(&models.Building{}).Where("name = ?", input.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ilyen nevű épület már létezik"})
+		return
+	}
+
+	building := models.Building{
+		Name:     input.Name,
+		Timezone: input.Timezone,
+	}
+
+	if err := h.db.Create(&building).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület létrehozása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, building)
+}
+
+func (h *BuildingHandler) UpdateBuilding(c *gin.Context) {
+	building, ok := h.findBuilding(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Timezone *string `json:"timezone"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok"})
+		return
+	}
+
+	if input.Timezone != nil {
+		if *input.Timezone != "" {
+			if _, err := utils.LoadLocation(*input.Timezone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Ismeretlen időzóna: " + *input.Timezone})
+				return
+			}
+		}
+		building.Timezone = *input.Timezone
+	}
+
+	if err := h.db.Save(building).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület frissítése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, building)
+}
+
+func (h *BuildingHandler) DeleteBuilding(c *gin.Context) {
+	building, ok := h.findBuilding(c)
+	if !ok {
+		return
+	}
+
+	var rooms int64
+	h.db.Model(&models.Room{}).Where("building_id = ?", building.ID).Count(&rooms)
+	if rooms > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Az épülethez még helyiségek tartoznak"})
+		return
+	}
+
+	// Losing their last building would leave its managers unrestricted.
+	if admins := h.db.Model(building).Association("Admins").Count(); admins > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Az épülethez még kezelők vannak rendelve"})
+		return
+	}
+
+	if err := h.db.Delete(building).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület törlése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Épület sikeresen törölve"})
+}
+
+func (h *BuildingHandler) findBuilding(c *gin.Context) (*models.Building, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen épület azonosító"})
+		return nil, false
+	}
+
+	var building models.Building
+	if err := h.db.First(&building, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Épület nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	if !currentBuildingScope(c).AllowsBuilding(building.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Épület nem található"})
+		return nil, false
+	}
+
+	return &building, true
+}
+
+func (h *BuildingHandler) GetBuildingApprovers(c *gin.Context) {
+	building, ok := h.findBuilding(c)
+	if !ok {
+		return
+	}
+
+	var approvers []models.User
+	if err := h.db.Model(building).Association("Approvers").Find(&approvers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jóváhagyók lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, approvers)
+}
+
+func (h *BuildingHandler) AddBuildingApprover(c *gin.Context) {
+	building, ok := h.findBuilding(c)
+	if !ok {
+		return
+	}
+
+	approver, ok := bindApprover(c, h.db, func(scope *utils.BuildingScope) bool {
+		return scope.AllowsBuilding(building.ID)
+	})
+	if !ok {
+		return
+	}
+
+	if err := h.db.Model(building).Association("Approvers").Append(approver); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jóváhagyó hozzáadása sikertelen"})
+		return
+	}
+
+	h.db.Model(building).Association("Approvers").Find(&building.Approvers)
+
+	c.JSON(http.StatusOK, building)
+}
+
+func (h *BuildingHandler) RemoveBuildingApprover(c *gin.Context) {
+	building, ok := h.findBuilding(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen felhasználó azonosító"})
+		return
+	}
+
+	if err := h.db.Model(building).Association("Approvers").Delete(&models.User{ID: uint(userID)}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jóváhagyó eltávolítása sikertelen"})
+		return
+	}
+
+	h.db.Model(building).Association("Approvers").Find(&building.Approvers)
+
+	c.JSON(http.StatusOK, building)
+}
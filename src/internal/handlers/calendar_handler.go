@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+)
+
+type CalendarHandler struct {
+	db        *gorm.DB
+	calendar  *utils.CalendarService
+	timezones *utils.TimezoneService
+}
+
+func NewCalendarHandler(db *gorm.DB) *CalendarHandler {
+	return &CalendarHandler{
+		db:        db,
+		calendar:  utils.NewCalendarService(db),
+		timezones: utils.NewTimezoneService(db),
+	}
+}
+
+type calendarExceptionInput struct {
+	Type      models.CalendarExceptionType `json:"type" binding:"required"`
+	Scope     models.CalendarScope         `json:"scope" binding:"required"`
+	RoomID    *uint                        `json:"room_id"`
+	Building  string                       `json:"building"`
+	StartDate string                       `json:"start_date" binding:"required"`
+	EndDate   string                       `json:"end_date"`
+	Hours     string                       `json:"hours"`
+	Reason    string                       `json:"reason" binding:"required"`
+}
+
+func (h *CalendarHandler) GetCalendarExceptions(c *gin.Context) {
+	var exceptions []models.CalendarException
+
+	query := h.db.Preload("Room")
+
+	if exceptionType := c.Query("type"); exceptionType != "" {
+		query = query.Where("type = ?", exceptionType)
+	}
+
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	if building := c.Query("building"); building != "" {
+		query = query.Where("building = ?", building)
+	}
+
+	if from := c.Query("from"); from != "" {
+		query = query.Where("end_date >= ?", from)
+	}
+
+	if to := c.Query("to"); to != "" {
+		query = query.Where("start_date <= ?", to)
+	}
+
+	if err := query.Order("start_date").Find(&exceptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivételek lekérése sikertelen"})
+		return
+	}
+
+	// Delegated administrators see the campus wide exceptions and the ones
+	// of their buildings.
+	if scope := currentBuildingScope(c); scope != nil {
+		visible := []models.CalendarException{}
+		for _, exception := range exceptions {
+			inScope, err := h.calendar.InScope(scope, exception)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivételek lekérése sikertelen"})
+				return
+			}
+			if inScope || exception.Scope == models.CalendarScopeCampus {
+				visible = append(visible, exception)
+			}
+		}
+		exceptions = visible
+	}
+
+	// A room filter includes the building and campus wide exceptions that
+	// also apply to the room.
+	if roomIDStr := c.Query("room_id"); roomIDStr != "" {
+		roomID, err := strconv.Atoi(roomIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
+			return
+		}
+
+		var room models.Room
+		if err := h.db.First(&room, roomID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Helyiség nem található"})
+			return
+		}
+
+		filtered := []models.CalendarException{}
+		for _, exception := range exceptions {
+			if exception.Covers(room) {
+				filtered = append(filtered, exception)
+			}
+		}
+		exceptions = filtered
+	}
+
+	c.JSON(http.StatusOK, exceptions)
+}
+
+func (h *CalendarHandler) GetCalendarException(c *gin.Context) {
+	exception, ok := h.findException(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, exception)
+}
+
+func (h *CalendarHandler) CreateCalendarException(c *gin.Context) {
+	var input calendarExceptionInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a típust, a hatókört, a kezdő dátumot és az okot."})
+		return
+	}
+
+	exception := models.CalendarException{
+		Type:      input.Type,
+		Scope:     input.Scope,
+		RoomID:    input.RoomID,
+		Building:  input.Building,
+		StartDate: input.StartDate,
+		EndDate:   input.EndDate,
+		Hours:     input.Hours,
+		Reason:    input.Reason,
+		Source:    "manual",
+		CreatedBy: currentUserID(c),
+	}
+
+	if !respondCalendarValidation(c, h.calendar.Validate(&exception)) {
+		return
+	}
+
+	if !h.respondOutOfScope(c, exception) {
+		return
+	}
+
+	if err := h.db.Create(&exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivétel létrehozása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, exception)
+}
+
+func (h *CalendarHandler) UpdateCalendarException(c *gin.Context) {
+	exception, ok := h.findException(c)
+	if !ok || !h.respondOutOfScope(c, *exception) {
+		return
+	}
+
+	var input calendarExceptionInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a típust, a hatókört, a kezdő dátumot és az okot."})
+		return
+	}
+
+	exception.Type = input.Type
+	exception.Scope = input.Scope
+	exception.RoomID = input.RoomID
+	exception.Building = input.Building
+	exception.StartDate = input.StartDate
+	exception.EndDate = input.EndDate
+	exception.Hours = input.Hours
+	exception.Reason = input.Reason
+	exception.Room = nil
+
+	if !respondCalendarValidation(c, h.calendar.Validate(exception)) {
+		return
+	}
+
+	if !h.respondOutOfScope(c, *exception) {
+		return
+	}
+
+	if err := h.db.Save(exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivétel frissítése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, exception)
+}
+
+func (h *CalendarHandler) DeleteCalendarException(c *gin.Context) {
+	exception, ok := h.findException(c)
+	if !ok || !h.respondOutOfScope(c, *exception) {
+		return
+	}
+
+	if err := h.db.Delete(exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivétel törlése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Naptári kivétel sikeresen törölve"})
+}
+
+// ImportCalendar reads an iCalendar file, either as the "file" field of a
+// multipart form or as the raw request body. The type and the scope of the
+// created exceptions come from the query string, timed events are converted
+// into the zone of that scope.
+func (h *CalendarHandler) ImportCalendar(c *gin.Context) {
+	template := models.CalendarException{
+		Type:      models.CalendarExceptionType(c.DefaultQuery("type", string(models.CalendarHoliday))),
+		Scope:     models.CalendarScope(c.DefaultQuery("scope", string(models.CalendarScopeCampus))),
+		Building:  c.Query("building"),
+		CreatedBy: currentUserID(c),
+	}
+
+	if roomIDStr := c.Query("room_id"); roomIDStr != "" {
+		roomID, err := strconv.Atoi(roomIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
+			return
+		}
+		id := uint(roomID)
+		template.RoomID = &id
+	}
+
+	if !h.respondOutOfScope(c, template) {
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Hiányzó fájl. Kérjük, a naptárat a file mezőben töltse fel."})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A feltöltött fájl nem olvasható"})
+			return
+		}
+		defer opened.Close()
+		body = opened
+	}
+
+	loc := h.timezones.DefaultLocation()
+	switch template.Scope {
+	case models.CalendarScopeRoom:
+		if template.RoomID != nil {
+			loc = h.timezones.RoomLocationByID(*template.RoomID)
+		}
+	case models.CalendarScopeBuilding:
+		var building models.Building
+		if err := h.db.Where("name = ?", template.Building).First(&building).Error; err == nil {
+			loc = h.timezones.BuildingLocation(&building.ID)
+		}
+	}
+
+	events, err := utils.ParseICal(body, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen iCalendar fájl: " + err.Error()})
+		return
+	}
+
+	if len(events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Az iCalendar fájl nem tartalmaz eseményt"})
+		return
+	}
+
+	result, err := h.calendar.Import(events, template)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptár importálása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *CalendarHandler) findException(c *gin.Context) (*models.CalendarException, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen kivétel azonosító"})
+		return nil, false
+	}
+
+	var exception models.CalendarException
+	if err := h.db.First(&exception, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Naptári kivétel nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivétel lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	if scope := currentBuildingScope(c); scope != nil && exception.Scope != models.CalendarScopeCampus {
+		inScope, err := h.calendar.InScope(scope, exception)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivétel lekérése sikertelen"})
+			return nil, false
+		}
+		if !inScope {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Naptári kivétel nem található"})
+			return nil, false
+		}
+	}
+
+	return &exception, true
+}
+
+// respondOutOfScope rejects writes outside the buildings of a delegated
+// administrator, campus wide exceptions included.
+func (h *CalendarHandler) respondOutOfScope(c *gin.Context, exception models.CalendarException) bool {
+	inScope, err := h.calendar.InScope(currentBuildingScope(c), exception)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivétel ellenőrzése sikertelen"})
+		return false
+	}
+	if !inScope {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return false
+	}
+	return true
+}
+
+func respondCalendarValidation(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, utils.ErrCalendarInvalidType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen típus vagy hatókör. Típus: holiday, closure vagy extra_opening, hatókör: campus, building vagy room."})
+	case errors.Is(err, utils.ErrCalendarInvalidDates):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen dátum tartomány. A dátumok formátuma ÉÉÉÉ-HH-NN, a vége nem lehet a kezdet előtt."})
+	case errors.Is(err, utils.ErrCalendarInvalidHours):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok: " + err.Error()})
+	case errors.Is(err, utils.ErrCalendarMissingTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A hatókörhöz meg kell adni egy létező helyiséget vagy épületet"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naptári kivétel ellenőrzése sikertelen"})
+	}
+	return false
+}
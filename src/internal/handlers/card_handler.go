@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,6 +17,7 @@ import (
 type CardHandler struct {
 	db            *gorm.DB
 	accessControl *utils.AccessControlService
+	devices       *utils.DeviceService
 	wsHandler     *websocket.WebSocketHandler
 	wsEnabled     bool
 }
@@ -26,6 +28,7 @@ func NewCardHandler(db *gorm.DB) *CardHandler {
 	return &CardHandler{
 		db:            db,
 		accessControl: accessControl,
+		devices:       utils.NewDeviceService(db),
 		wsEnabled:     false,
 	}
 }
@@ -82,10 +85,12 @@ func (h *CardHandler) GetCard(c *gin.Context) {
 
 func (h *CardHandler) CreateCard(c *gin.Context) {
 	var input struct {
-		UserID     uint              `json:"user_id" binding:"required"`
-		CardID     string            `json:"card_id" binding:"required"`
-		Status     models.CardStatus `json:"status"`
-		ExpiryDate *time.Time        `json:"expiry_date"`
+		UserID         uint                  `json:"user_id" binding:"required"`
+		CardID         string                `json:"card_id" binding:"required"`
+		CredentialType models.CredentialType `json:"credential_type"`
+		Label          string                `json:"label"`
+		Status         models.CardStatus     `json:"status"`
+		ExpiryDate     *time.Time            `json:"expiry_date"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -99,9 +104,11 @@ func (h *CardHandler) CreateCard(c *gin.Context) {
 		return
 	}
 
-	var existingUserCard models.Card
-	if result := h.db.Where("user_id = ?", input.UserID).First(&existingUserCard); result.Error == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "A felhasználónak már van kártyája. Egy felhasználóhoz csak egy kártya tartozhat."})
+	if input.CredentialType == "" {
+		input.CredentialType = models.CredentialCard
+	}
+	if !input.CredentialType.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen azonosító típus. Megengedett értékek: card, mobile_nfc, pin"})
 		return
 	}
 
@@ -116,7 +123,7 @@ func (h *CardHandler) CreateCard(c *gin.Context) {
 		return
 	}
 
-	card, err := h.accessControl.RegisterCard(input.UserID, input.CardID)
+	card, err := h.accessControl.RegisterCredential(input.UserID, input.CredentialType, input.CardID, input.Label)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kártya regisztrálása sikertelen: " + err.Error()})
 		return
@@ -142,9 +149,10 @@ func (h *CardHandler) CreateCard(c *gin.Context) {
 		event := map[string]interface{}{
 			"action": "card_created",
 			"card": map[string]interface{}{
-				"id":      card.ID,
-				"card_id": card.CardID,
-				"status":  card.Status,
+				"id":              card.ID,
+				"card_id":         card.CardID,
+				"credential_type": card.CredentialType,
+				"status":          card.Status,
 			},
 			"user": map[string]interface{}{
 				"id":   user.ID,
@@ -179,6 +187,7 @@ func (h *CardHandler) UpdateCard(c *gin.Context) {
 		Status      models.CardStatus `json:"status"`
 		ExpiryDate  *time.Time        `json:"expiry_date"`
 		CardID      string            `json:"card_id"`
+		Label       *string           `json:"label"`
 		Description string            `json:"description"`
 	}
 
@@ -197,14 +206,6 @@ func (h *CardHandler) UpdateCard(c *gin.Context) {
 			return
 		}
 
-		if *input.UserID != card.UserID {
-			var existingUserCard models.Card
-			if result := h.db.Where("user_id = ?", *input.UserID).First(&existingUserCard); result.Error == nil {
-				c.JSON(http.StatusConflict, gin.H{"error": "A kiválasztott felhasználónak már van kártyája. Egy felhasználóhoz csak egy kártya tartozhat."})
-				return
-			}
-		}
-
 		card.UserID = *input.UserID
 	}
 
@@ -216,6 +217,10 @@ func (h *CardHandler) UpdateCard(c *gin.Context) {
 		card.ExpiryDate = input.ExpiryDate
 	}
 
+	if input.Label != nil {
+		card.Label = *input.Label
+	}
+
 	if input.CardID != "" && input.CardID != card.CardID {
 		var existingCard models.Card
 		if result := h.db.Where("card_id = ? AND id != ?", input.CardID, card.ID).First(&existingCard); result.Error == nil {
@@ -351,9 +356,10 @@ func (h *CardHandler) BlockCard(c *gin.Context) {
 		event := map[string]interface{}{
 			"action": "card_blocked",
 			"card": map[string]interface{}{
-				"id":      card.ID,
-				"card_id": card.CardID,
-				"status":  card.Status,
+				"id":              card.ID,
+				"card_id":         card.CardID,
+				"credential_type": card.CredentialType,
+				"status":          card.Status,
 			},
 		}
 
@@ -394,9 +400,10 @@ func (h *CardHandler) UnblockCard(c *gin.Context) {
 		event := map[string]interface{}{
 			"action": "card_unblocked",
 			"card": map[string]interface{}{
-				"id":      card.ID,
-				"card_id": card.CardID,
-				"status":  card.Status,
+				"id":              card.ID,
+				"card_id":         card.CardID,
+				"credential_type": card.CredentialType,
+				"status":          card.Status,
 			},
 		}
 
@@ -437,9 +444,10 @@ func (h *CardHandler) RevokeCard(c *gin.Context) {
 		event := map[string]interface{}{
 			"action": "card_revoked",
 			"card": map[string]interface{}{
-				"id":      card.ID,
-				"card_id": card.CardID,
-				"status":  card.Status,
+				"id":              card.ID,
+				"card_id":         card.CardID,
+				"credential_type": card.CredentialType,
+				"status":          card.Status,
 			},
 		}
 
@@ -494,9 +502,12 @@ func (h *CardHandler) GetExpiringCards(c *gin.Context) {
 
 func (h *CardHandler) CheckAccess(c *gin.Context) {
 	var input struct {
-		CardID   string `json:"card_id" binding:"required"`
-		RoomID   uint   `json:"room_id" binding:"required"`
-		DeviceID string `json:"device_id"`
+		CardID         string                 `json:"card_id" binding:"required"`
+		CredentialType models.CredentialType  `json:"credential_type"`
+		PIN            string                 `json:"pin"`
+		RoomID         uint                   `json:"room_id" binding:"required"`
+		DeviceID       string                 `json:"device_id"`
+		Direction      models.AccessDirection `json:"direction"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -504,8 +515,82 @@ func (h *CardHandler) CheckAccess(c *gin.Context) {
 		return
 	}
 
+	if !input.Direction.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen irány. Megengedett értékek: entry, exit"})
+		return
+	}
+
+	if input.CredentialType != "" && !input.CredentialType.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen azonosító típus. Megengedett értékek: card, mobile_nfc, pin"})
+		return
+	}
+
+	h.respondAccess(c, utils.AccessRequest{
+		CardID:         input.CardID,
+		CredentialType: input.CredentialType,
+		RoomID:         input.RoomID,
+		DeviceID:       input.DeviceID,
+		Direction:      input.Direction,
+		PIN:            input.PIN,
+	})
+}
+
+// ReaderCheckAccess serves the card readers. The reader has to be registered
+// and enabled, and it may only ask about its own room and direction.
+func (h *CardHandler) ReaderCheckAccess(c *gin.Context) {
+	var input struct {
+		CardID         string                 `json:"card_id" binding:"required"`
+		CredentialType models.CredentialType  `json:"credential_type"`
+		PIN            string                 `json:"pin"`
+		RoomID         uint                   `json:"room_id"`
+		DeviceID       string                 `json:"device_id"`
+		Direction      models.AccessDirection `json:"direction"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a kártya azonosítót és az olvasó azonosítóját."})
+		return
+	}
+
+	if !input.Direction.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen irány. Megengedett értékek: entry, exit"})
+		return
+	}
+
+	if input.CredentialType != "" && !input.CredentialType.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen azonosító típus. Megengedett értékek: card, mobile_nfc, pin"})
+		return
+	}
+
+	req := utils.AccessRequest{
+		CardID:         input.CardID,
+		CredentialType: input.CredentialType,
+		RoomID:         input.RoomID,
+		Direction:      input.Direction,
+		PIN:            input.PIN,
+	}
+
+	device, err := h.devices.ResolveReader(apiKeyDeviceID(c), input.DeviceID)
+	if err == nil {
+		err = h.devices.BindRequest(device, &req)
+	}
+	if err != nil {
+		switch err {
+		case utils.ErrDeviceUnknown, utils.ErrDeviceDisabled, utils.ErrDeviceMismatch:
+			log.Printf("Elutasított olvasó kérés (eszköz: %s, helyiség: %d): %v", input.DeviceID, input.RoomID, err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Az olvasó nem jogosult a kérésre: " + err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó adatok lekérése sikertelen"})
+		}
+		return
+	}
+
+	h.respondAccess(c, req)
+}
+
+func (h *CardHandler) respondAccess(c *gin.Context, req utils.AccessRequest) {
 	var room models.Room
-	if err := h.db.First(&room, input.RoomID).Error; err != nil {
+	if err := h.db.First(&room, req.RoomID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Helyiség nem található"})
 		} else {
@@ -514,7 +599,7 @@ func (h *CardHandler) CheckAccess(c *gin.Context) {
 		return
 	}
 
-	hasAccess, reason, err := h.accessControl.CheckAccess(input.CardID, input.RoomID, input.DeviceID)
+	hasAccess, reason, err := h.accessControl.Authorize(req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférés ellenőrzése sikertelen: " + err.Error()})
 		return
@@ -539,21 +624,37 @@ func (h *CardHandler) CheckAccess(c *gin.Context) {
 			reasonText = "Visszavont kártya"
 		case models.DenialReasonPermissionError:
 			reasonText = "Jogosultság hiba"
+		case models.DenialReasonTimeRestricted:
+			reasonText = "A jogosultság időkorlátozásán kívül"
+		case models.DenialReasonAntiPassback:
+			reasonText = "Ismételt belépés kilépés nélkül"
+		case models.DenialReasonCapacityReached:
+			reasonText = "A helyiség megtelt"
+		case models.DenialReasonPINRequired:
+			reasonText = "PIN kód szükséges"
+		case models.DenialReasonInvalidPIN:
+			reasonText = "Hibás PIN kód"
+		case models.DenialReasonPINLocked:
+			reasonText = "A PIN kód zárolva"
+		case models.DenialReasonDualAuthPending:
+			reasonText = "Második személy érintésére vár"
+		case models.DenialReasonTooManyAttempts:
+			reasonText = "Túl sok ismeretlen azonosító, próbálja később"
 		default:
 			reasonText = string(reason)
 		}
 	}
 
-	var card models.Card
 	var user models.User
 	cardData := gin.H{}
 
-	if result := h.db.Where("card_id = ?", input.CardID).First(&card); result.Error == nil {
+	if card, err := h.accessControl.FindCredential(req.CredentialType, req.CardID); err == nil {
 		if h.db.First(&user, card.UserID).Error == nil {
 			cardData = gin.H{
-				"id":      card.ID,
-				"card_id": card.CardID,
-				"status":  card.Status,
+				"id":              card.ID,
+				"card_id":         req.CardID,
+				"credential_type": card.CredentialType,
+				"status":          card.Status,
 				"user": gin.H{
 					"id":   user.ID,
 					"name": user.FirstName + " " + user.LastName,
@@ -570,6 +671,7 @@ func (h *CardHandler) CheckAccess(c *gin.Context) {
 			"building":    room.Building,
 			"room_number": room.RoomNumber,
 		},
+		"direction":   req.Direction,
 		"reason_code": string(reason),
 		"reason_text": reasonText,
 		"card":        cardData,
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+)
+
+func currentUserID(c *gin.Context) uint {
+	if userID, exists := c.Get("userID"); exists {
+		if id, ok := userID.(uint); ok {
+			return id
+		}
+	}
+	return 0
+}
+
+func apiKeyDeviceID(c *gin.Context) *uint {
+	if deviceID, exists := c.Get("apiKeyDeviceID"); exists {
+		if id, ok := deviceID.(uint); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// currentUser has the roles and buildings preloaded.
+func currentUser(c *gin.Context) models.User {
+	value, _ := c.Get("user")
+	user, _ := value.(models.User)
+	return user
+}
+
+func currentUserHasPrivilege(c *gin.Context, privilege models.Privilege) bool {
+	value, _ := c.Get("user")
+	user, ok := value.(models.User)
+	return ok && user.HasPrivilege(privilege)
+}
+
+// canGrantAdmin reports whether the caller may hand out or take away admin
+// rights. Without it is_admin in a request body is ignored, otherwise
+// users.write alone would reach every privilege.
+func canGrantAdmin(c *gin.Context) bool {
+	return currentUserHasPrivilege(c, models.PrivilegeRolesManage)
+}
+
+// currentBuildingScope is nil for users managing every building and for
+// API key callers.
+func currentBuildingScope(c *gin.Context) *utils.BuildingScope {
+	value, _ := c.Get("user")
+	user, ok := value.(models.User)
+	if !ok {
+		return nil
+	}
+	return utils.NewBuildingScope(user)
+}
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+	"rfid/internal/websocket"
+)
+
+type DeviceHandler struct {
+	db      *gorm.DB
+	apiKeys *utils.APIKeyService
+	devices *utils.DeviceService
+	monitor *utils.DeviceMonitor
+	offline *utils.OfflineAccessService
+
+	wsHandler *websocket.WebSocketHandler
+	wsEnabled bool
+}
+
+func NewDeviceHandler(db *gorm.DB) *DeviceHandler {
+	return &DeviceHandler{
+		db:      db,
+		apiKeys: utils.NewAPIKeyService(db),
+		devices: utils.NewDeviceService(db),
+	}
+}
+
+func (h *DeviceHandler) SetDeviceMonitor(monitor *utils.DeviceMonitor) {
+	h.monitor = monitor
+}
+
+func (h *DeviceHandler) SetOfflineAccessService(offline *utils.OfflineAccessService) {
+	h.offline = offline
+}
+
+func (h *DeviceHandler) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	h.wsHandler = wsHandler
+	h.wsEnabled = (wsHandler != nil)
+}
+
+func (h *DeviceHandler) GetDevices(c *gin.Context) {
+	var devices []models.Device
+
+	query := h.db.Preload("Room")
+
+	if roomID := c.Query("room_id"); roomID != "" {
+		query = query.Where("room_id = ?", roomID)
+	}
+
+	if enabled := c.Query("enabled"); enabled != "" {
+		query = query.Where("enabled = ?", enabled == "true")
+	}
+
+	if err := query.Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasók lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+func (h *DeviceHandler) GetDevice(c *gin.Context) {
+	device, ok := h.findDevice(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func (h *DeviceHandler) CreateDevice(c *gin.Context) {
+	var input struct {
+		DeviceID    string                 `json:"device_id" binding:"required"`
+		Name        string                 `json:"name" binding:"required"`
+		Description string                 `json:"description"`
+		RoomID      uint                   `json:"room_id" binding:"required"`
+		Direction   models.AccessDirection `json:"direction"`
+		Enabled     *bool                  `json:"enabled"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg az olvasó azonosítóját, nevét és helyiségét."})
+		return
+	}
+
+	input.DeviceID = strings.TrimSpace(input.DeviceID)
+	if input.DeviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Az olvasó azonosítója nem lehet üres"})
+		return
+	}
+
+	if !input.Direction.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen irány. Megengedett értékek: entry, exit"})
+		return
+	}
+
+	if !h.roomExists(c, input.RoomID) {
+		return
+	}
+
+	var existing int64
+	if err := h.db.Model(&models.Device{}).Where("device_id = ?", input.DeviceID).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó ellenőrzése sikertelen"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ezzel az azonosítóval már létezik olvasó"})
+		return
+	}
+
+	device := models.Device{
+		DeviceID:    input.DeviceID,
+		Name:        input.Name,
+		Description: input.Description,
+		RoomID:      input.RoomID,
+		Direction:   input.Direction,
+		Enabled:     true,
+	}
+
+	if err := h.db.Create(&device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó létrehozása sikertelen"})
+		return
+	}
+
+	// gorm replaces a false value with the column default on create, so a
+	// device registered as disabled needs an explicit update.
+	if input.Enabled != nil && !*input.Enabled {
+		if err := h.db.Model(&device).Update("enabled", false).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó letiltása sikertelen"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, device)
+}
+
+func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
+	device, ok := h.findDevice(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name        string                  `json:"name"`
+		Description *string                 `json:"description"`
+		RoomID      uint                    `json:"room_id"`
+		Direction   *models.AccessDirection `json:"direction"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Direction != nil && !input.Direction.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen irány. Megengedett értékek: entry, exit"})
+		return
+	}
+
+	if input.RoomID != 0 && input.RoomID != device.RoomID {
+		if !h.roomExists(c, input.RoomID) {
+			return
+		}
+		device.RoomID = input.RoomID
+		device.Room = models.Room{}
+	}
+
+	if input.Name != "" {
+		device.Name = input.Name
+	}
+	if input.Description != nil {
+		device.Description = *input.Description
+	}
+	if input.Direction != nil {
+		device.Direction = *input.Direction
+	}
+
+	if err := h.db.Omit("Room").Save(device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó frissítése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func (h *DeviceHandler) DeleteDevice(c *gin.Context) {
+	device, ok := h.findDevice(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeys.RevokeDeviceKeys(device.ID, currentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Az olvasó API kulcsainak visszavonása sikertelen"})
+		return
+	}
+
+	if err := h.db.Delete(device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó törlése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Olvasó sikeresen törölve"})
+}
+
+func (h *DeviceHandler) EnableDevice(c *gin.Context) {
+	h.setEnabled(c, true, "Olvasó sikeresen engedélyezve")
+}
+
+func (h *DeviceHandler) DisableDevice(c *gin.Context) {
+	h.setEnabled(c, false, "Olvasó sikeresen letiltva")
+}
+
+func (h *DeviceHandler) setEnabled(c *gin.Context, enabled bool, message string) {
+	device, ok := h.findDevice(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Model(device).Update("enabled", enabled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó állapotának módosítása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "device": device})
+}
+
+func (h *DeviceHandler) GetDeviceHealth(c *gin.Context) {
+	report, err := h.monitor.Health()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasók állapotának lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Heartbeat receives the periodic status report of a reader.
+func (h *DeviceHandler) Heartbeat(c *gin.Context) {
+	var input struct {
+		DeviceID string `json:"device_id"`
+		models.DeviceHeartbeat
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen heartbeat adatok"})
+		return
+	}
+
+	device, ok := h.resolveReader(c, input.DeviceID)
+	if !ok {
+		return
+	}
+
+	if err := h.monitor.Heartbeat(device, input.DeviceHeartbeat, c.ClientIP()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Heartbeat mentése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id":   device.DeviceID,
+		"server_time": time.Now().Format(time.RFC3339),
+	})
+}
+
+// GetAccessList returns the signed offline access list of the calling
+// reader, as a delta when since names a version the server still keeps.
+func (h *DeviceHandler) GetAccessList(c *gin.Context) {
+	device, ok := h.resolveReader(c, c.Query("device_id"))
+	if !ok {
+		return
+	}
+
+	since := 0
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		value, err := strconv.Atoi(sinceStr)
+		if err != nil || value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen verziószám"})
+			return
+		}
+		since = value
+	}
+
+	list, err := h.offline.AccessList(device, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Offline hozzáférési lista készítése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *DeviceHandler) GetAccessListPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "ed25519",
+		"public_key": h.offline.PublicKey(),
+	})
+}
+
+func (h *DeviceHandler) UploadOfflineSwipes(c *gin.Context) {
+	var input struct {
+		DeviceID string                `json:"device_id"`
+		Swipes   []models.OfflineSwipe `json:"swipes" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg az offline kártyahasználatokat."})
+		return
+	}
+
+	device, ok := h.resolveReader(c, input.DeviceID)
+	if !ok {
+		return
+	}
+
+	result, err := h.offline.ImportSwipes(device, input.Swipes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Offline kártyahasználatok mentése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SendCommand pushes a maintenance command to a connected reader and waits
+// for its acknowledgement. Door commands are only sent through the room
+// unlock and lock endpoints, which log the actor and reason.
+func (h *DeviceHandler) SendCommand(c *gin.Context) {
+	device, ok := h.findDevice(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Command     string                 `json:"command" binding:"required"`
+		Params      map[string]interface{} `json:"params"`
+		WaitSeconds *int                   `json:"wait_seconds"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a parancsot."})
+		return
+	}
+
+	if input.Command != websocket.ReaderCommandRefreshCache {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen parancs. Megengedett érték: refresh_cache, az ajtók a helyiség nyitás és zárás végpontjain vezérelhetők"})
+		return
+	}
+
+	if !h.wsEnabled {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "A WebSocket kapcsolat nincs engedélyezve"})
+		return
+	}
+
+	wait := 5 * time.Second
+	if input.WaitSeconds != nil {
+		if *input.WaitSeconds < 0 || *input.WaitSeconds > 30 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A várakozási idő 0 és 30 másodperc között lehet"})
+			return
+		}
+		wait = time.Duration(*input.WaitSeconds) * time.Second
+	}
+
+	commandID, ack, err := h.wsHandler.GetHub().SendCommand(device.ID, websocket.ReaderCommand{
+		Command: input.Command,
+		Params:  input.Params,
+	}, wait)
+
+	switch err {
+	case nil:
+	case websocket.ErrReaderNotConnected:
+		c.JSON(http.StatusConflict, gin.H{"error": "Az olvasó nem kapcsolódik"})
+		return
+	case websocket.ErrCommandTimeout:
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Az olvasó nem igazolta vissza a parancsot", "command_id": commandID})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Parancs küldése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"command_id":   commandID,
+		"command":      input.Command,
+		"acknowledged": ack != nil,
+		"ack":          ack,
+	})
+}
+
+func (h *DeviceHandler) resolveReader(c *gin.Context, deviceID string) (*models.Device, bool) {
+	device, err := h.devices.ResolveReader(apiKeyDeviceID(c), deviceID)
+	if err != nil {
+		switch err {
+		case utils.ErrDeviceUnknown, utils.ErrDeviceDisabled, utils.ErrDeviceMismatch:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Az olvasó nem jogosult a kérésre: " + err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó adatok lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	return device, true
+}
+
+func (h *DeviceHandler) findDevice(c *gin.Context) (*models.Device, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen olvasó azonosító"})
+		return nil, false
+	}
+
+	var device models.Device
+	if err := h.db.Preload("Room").First(&device, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Olvasó nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Olvasó lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	return &device, true
+}
+
+func (h *DeviceHandler) roomExists(c *gin.Context, roomID uint) bool {
+	var room models.Room
+	if err := h.db.First(&room, roomID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A megadott helyiség nem létezik"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség lekérése sikertelen"})
+		}
+		return false
+	}
+
+	return true
+}
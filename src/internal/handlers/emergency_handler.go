@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+	"rfid/internal/websocket"
+)
+
+type EmergencyHandler struct {
+	db        *gorm.DB
+	emergency *utils.EmergencyService
+}
+
+func NewEmergencyHandler(db *gorm.DB) *EmergencyHandler {
+	return &EmergencyHandler{
+		db:        db,
+		emergency: utils.NewEmergencyService(db),
+	}
+}
+
+func (h *EmergencyHandler) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	h.emergency.SetWebSocketHandler(wsHandler)
+}
+
+func (h *EmergencyHandler) GetEmergencies(c *gin.Context) {
+	var states []models.EmergencyState
+
+	query := h.db.Preload("Room").Preload("ResponderGroup")
+
+	if c.Query("active") == "true" {
+		query = query.Where("active = ?", true)
+	}
+
+	if mode := c.Query("mode"); mode != "" {
+		query = query.Where("mode = ?", mode)
+	}
+
+	if err := query.Order("activated_at DESC").Find(&states).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Vészhelyzetek lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, states)
+}
+
+func (h *EmergencyHandler) GetEmergency(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen vészhelyzet azonosító"})
+		return
+	}
+
+	var state models.EmergencyState
+	if err := h.db.Preload("Room").Preload("ResponderGroup").First(&state, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Vészhelyzet nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Vészhelyzet lekérése sikertelen"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func (h *EmergencyHandler) ActivateEmergency(c *gin.Context) {
+	var input utils.EmergencyActivation
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a módot, a hatókört és az okot."})
+		return
+	}
+
+	state, err := h.emergency.Activate(input, currentUserID(c), currentBuildingScope(c))
+	switch err {
+	case nil:
+	case utils.ErrEmergencyInvalid:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen mód vagy hatókör. Mód: lockdown vagy evacuation, hatókör: room, building vagy campus."})
+		return
+	case utils.ErrEmergencyTargetMissing:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A megadott helyiség, épület vagy beavatkozó csoport nem létezik"})
+		return
+	case utils.ErrBuildingOutOfScope:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Vészhelyzet aktiválása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, state)
+}
+
+func (h *EmergencyHandler) ClearEmergency(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen vészhelyzet azonosító"})
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok"})
+		return
+	}
+
+	state, err := h.emergency.Clear(uint(id), currentUserID(c), input.Reason, currentBuildingScope(c))
+	switch err {
+	case nil:
+	case gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vészhelyzet nem található"})
+		return
+	case utils.ErrEmergencyNotActive:
+		c.JSON(http.StatusConflict, gin.H{"error": "A vészhelyzet már fel lett oldva"})
+		return
+	case utils.ErrBuildingOutOfScope:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Vészhelyzet feloldása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
@@ -3,19 +3,25 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"rfid/internal/models"
+	"rfid/internal/utils"
 )
 
 type GroupHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	hierarchy *utils.GroupHierarchyService
 }
 
 func NewGroupHandler(db *gorm.DB) *GroupHandler {
-	return &GroupHandler{db: db}
+	return &GroupHandler{
+		db:        db,
+		hierarchy: utils.NewGroupHierarchyService(db),
+	}
 }
 
 func (h *GroupHandler) GetGroups(c *gin.Context) {
@@ -139,7 +145,12 @@ func (h *GroupHandler) CreateGroup(c *gin.Context) {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Egy vagy több szoba nem található"})
 				return
 			}
-			if err := tx.Model(&group).Association("Rooms").Append(&room); err != nil {
+			if !h.roomInScope(c, room) {
+				tx.Rollback()
+				return
+			}
+			groupRoom := models.GroupRoom{GroupID: group.ID, RoomID: room.ID}
+			if err := grantGroupRoom(tx, &groupRoom, currentUserID(c), nil, nil, ""); err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Hiba történt a szobák hozzáadásakor"})
 				return
@@ -201,6 +212,18 @@ func (h *GroupHandler) UpdateGroup(c *gin.Context) {
 		return
 	}
 
+	if input.ParentID != nil {
+		hasCycle, err := h.hierarchy.WouldCreateCycle(group.ID, *input.ParentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Hiba történt a csoport hierarchia ellenőrzésekor"})
+			return
+		}
+		if hasCycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A megadott szülő csoport körkörös hierarchiát hozna létre"})
+			return
+		}
+	}
+
 	if input.Name != "" {
 		group.Name = input.Name
 	}
@@ -244,25 +267,62 @@ func (h *GroupHandler) UpdateGroup(c *gin.Context) {
 		}
 	}
 
+	// Rooms already granted keep their grant as it is, with its validity,
+	// schedule and revocation. Only missing rooms are added and dropped
+	// rooms removed.
 	if input.RoomIDs != nil {
-		if err := tx.Model(&group).Association("Rooms").Clear(); err != nil {
+		var existing []models.GroupRoom
+		if err := tx.Where("group_id = ?", group.ID).Find(&existing).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Hiba történt a szobák eltávolításakor"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Csoport szobáinak lekérdezése sikertelen"})
 			return
 		}
 
+		granted := make(map[uint]bool)
+		for _, groupRoom := range existing {
+			granted[groupRoom.RoomID] = true
+		}
+
+		wanted := make(map[uint]bool)
 		for _, roomID := range input.RoomIDs {
+			wanted[roomID] = true
+			if granted[roomID] {
+				continue
+			}
+
 			var room models.Room
 			if err := tx.First(&room, roomID).Error; err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Egy vagy több szoba nem található"})
 				return
 			}
-			if err := tx.Model(&group).Association("Rooms").Append(&room); err != nil {
+			if !h.roomInScope(c, room) {
+				tx.Rollback()
+				return
+			}
+
+			groupRoom := models.GroupRoom{GroupID: group.ID, RoomID: room.ID}
+			if err := grantGroupRoom(tx, &groupRoom, currentUserID(c), nil, nil, ""); err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Hiba történt a szobák hozzáadásakor"})
 				return
 			}
+			granted[roomID] = true
+		}
+
+		for _, groupRoom := range existing {
+			if wanted[groupRoom.RoomID] {
+				continue
+			}
+			if !h.grantRoomInScope(c, groupRoom.RoomID) {
+				tx.Rollback()
+				return
+			}
+			if err := tx.Where("group_id = ? AND room_id = ?", group.ID, groupRoom.RoomID).Delete(&models.GroupRoom{}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Hiba történt a szobák eltávolításakor"})
+				return
+			}
 		}
 	}
 
@@ -435,7 +495,10 @@ func (h *GroupHandler) AddRoomToGroup(c *gin.Context) {
 	}
 
 	var input struct {
-		RoomID uint `json:"room_id" binding:"required"`
+		RoomID          uint       `json:"room_id" binding:"required"`
+		ValidFrom       *time.Time `json:"valid_from"`
+		ValidUntil      *time.Time `json:"valid_until"`
+		TimeRestriction string     `json:"time_restriction"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -443,6 +506,17 @@ func (h *GroupHandler) AddRoomToGroup(c *gin.Context) {
 		return
 	}
 
+	schedule, err := models.ParseSchedule(input.TimeRestriction)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen időkorlátozás: " + err.Error()})
+		return
+	}
+
+	if input.ValidFrom != nil && input.ValidUntil != nil && input.ValidUntil.Before(*input.ValidFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Az érvényesség vége nem lehet korábbi a kezdeténél"})
+		return
+	}
+
 	var group models.Group
 	if err := h.db.First(&group, groupID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -463,27 +537,167 @@ func (h *GroupHandler) AddRoomToGroup(c *gin.Context) {
 		return
 	}
 
-	isInGroup := false
-	var rooms []models.Room
-	h.db.Model(&group).Association("Rooms").Find(&rooms)
-	for _, r := range rooms {
-		if r.ID == room.ID {
-			isInGroup = true
-			break
-		}
+	if !h.roomInScope(c, room) {
+		return
 	}
 
-	if isInGroup {
+	var groupRoom models.GroupRoom
+	result := h.db.Where("group_id = ? AND room_id = ?", group.ID, room.ID).First(&groupRoom)
+	if result.Error == nil && groupRoom.Active {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "A szoba már hozzá van rendelve a csoporthoz"})
 		return
+	} else if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Csoport szobáinak lekérdezése sikertelen"})
+		return
 	}
 
-	if err := h.db.Model(&group).Association("Rooms").Append(&room); err != nil {
+	groupRoom.GroupID = group.ID
+	groupRoom.RoomID = room.ID
+
+	if err := grantGroupRoom(h.db, &groupRoom, currentUserID(c), input.ValidFrom, input.ValidUntil, schedule.String()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "A szoba hozzáadása sikertelen"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Szoba sikeresen hozzáadva a csoporthoz"})
+	groupRoom.Room = room
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Szoba sikeresen hozzáadva a csoporthoz",
+		"grant":   groupRoom,
+	})
+}
+
+// grantGroupRoom (re)activates the grant of the room to the group, replacing
+// its validity and schedule.
+func grantGroupRoom(tx *gorm.DB, groupRoom *models.GroupRoom, grantedBy uint, validFrom, validUntil *time.Time, restriction string) error {
+	now := time.Now()
+	groupRoom.GrantedBy = grantedBy
+	groupRoom.GrantedAt = &now
+	groupRoom.ValidFrom = validFrom
+	groupRoom.ValidUntil = validUntil
+	groupRoom.TimeRestriction = restriction
+	groupRoom.Active = true
+	groupRoom.RevokedAt = nil
+	groupRoom.RevokedBy = nil
+
+	return tx.Save(groupRoom).Error
+}
+
+func (h *GroupHandler) UpdateGroupRoom(c *gin.Context) {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen csoport azonosító"})
+		return
+	}
+
+	roomID, err := strconv.Atoi(c.Param("room_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen szoba azonosító"})
+		return
+	}
+
+	var groupRoom models.GroupRoom
+	if err := h.db.Where("group_id = ? AND room_id = ?", groupID, roomID).First(&groupRoom).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "A szoba nincs hozzárendelve a csoporthoz"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Csoport szobáinak lekérdezése sikertelen"})
+		}
+		return
+	}
+
+	if !h.grantRoomInScope(c, groupRoom.RoomID) {
+		return
+	}
+
+	var input struct {
+		ValidFrom       *time.Time `json:"valid_from"`
+		ValidUntil      *time.Time `json:"valid_until"`
+		TimeRestriction *string    `json:"time_restriction"`
+		Active          *bool      `json:"active"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.ValidFrom != nil {
+		groupRoom.ValidFrom = input.ValidFrom
+	}
+	if input.ValidUntil != nil {
+		groupRoom.ValidUntil = input.ValidUntil
+	}
+	if input.TimeRestriction != nil {
+		schedule, err := models.ParseSchedule(*input.TimeRestriction)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen időkorlátozás: " + err.Error()})
+			return
+		}
+		groupRoom.TimeRestriction = schedule.String()
+	}
+	if groupRoom.ValidFrom != nil && groupRoom.ValidUntil != nil && groupRoom.ValidUntil.Before(*groupRoom.ValidFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Az érvényesség vége nem lehet korábbi a kezdeténél"})
+		return
+	}
+	if input.Active != nil {
+		groupRoom.Active = *input.Active
+		if groupRoom.Active {
+			groupRoom.RevokedAt = nil
+			groupRoom.RevokedBy = nil
+		}
+	}
+
+	if err := h.db.Save(&groupRoom).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "A csoport szoba jogosultságának frissítése sikertelen"})
+		return
+	}
+
+	h.db.Preload("Room").Where("group_id = ? AND room_id = ?", groupID, roomID).First(&groupRoom)
+
+	c.JSON(http.StatusOK, groupRoom)
+}
+
+func (h *GroupHandler) RevokeGroupRoom(c *gin.Context) {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen csoport azonosító"})
+		return
+	}
+
+	roomID, err := strconv.Atoi(c.Param("room_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen szoba azonosító"})
+		return
+	}
+
+	var groupRoom models.GroupRoom
+	if err := h.db.Where("group_id = ? AND room_id = ?", groupID, roomID).First(&groupRoom).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "A szoba nincs hozzárendelve a csoporthoz"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Csoport szobáinak lekérdezése sikertelen"})
+		}
+		return
+	}
+
+	if !h.grantRoomInScope(c, groupRoom.RoomID) {
+		return
+	}
+
+	now := time.Now()
+	revokedBy := currentUserID(c)
+
+	groupRoom.Active = false
+	groupRoom.RevokedAt = &now
+	groupRoom.RevokedBy = &revokedBy
+
+	if err := h.db.Save(&groupRoom).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "A csoport szoba jogosultságának visszavonása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Csoport szoba jogosultság sikeresen visszavonva"})
 }
 
 func (h *GroupHandler) RemoveRoomFromGroup(c *gin.Context) {
@@ -519,6 +733,10 @@ func (h *GroupHandler) RemoveRoomFromGroup(c *gin.Context) {
 		return
 	}
 
+	if !h.roomInScope(c, room) {
+		return
+	}
+
 	if err := h.db.Model(&group).Association("Rooms").Delete(&room); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "A szoba eltávolítása sikertelen"})
 		return
@@ -570,11 +788,68 @@ func (h *GroupHandler) GetGroupRooms(c *gin.Context) {
 		return
 	}
 
-	var rooms []models.Room
-	if err := h.db.Model(&group).Association("Rooms").Find(&rooms); err != nil {
+	query := h.db.Preload("Room").Where("group_id = ?", group.ID)
+
+	if activeStr := c.Query("active"); activeStr != "" {
+		query = query.Where("active = ?", activeStr == "true")
+	}
+
+	var groupRooms []models.GroupRoom
+	if err := query.Find(&groupRooms).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Szobák lekérdezése sikertelen"})
 		return
 	}
 
+	c.JSON(http.StatusOK, groupRooms)
+}
+
+func (h *GroupHandler) GetGroupEffectiveRooms(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen csoport azonosító"})
+		return
+	}
+
+	var group models.Group
+	if err := h.db.First(&group, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Csoport nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Csoport lekérdezése sikertelen"})
+		}
+		return
+	}
+
+	rooms, err := h.hierarchy.EffectiveRooms(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Az örökölt szobák lekérdezése sikertelen"})
+		return
+	}
+
 	c.JSON(http.StatusOK, rooms)
 }
+
+// roomInScope rejects group grants on rooms outside the buildings of a
+// delegated administrator.
+func (h *GroupHandler) roomInScope(c *gin.Context, room models.Room) bool {
+	if !currentBuildingScope(c).AllowsRoom(room) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return false
+	}
+	return true
+}
+
+// grantRoomInScope is roomInScope for an existing grant, whose room is not
+// loaded.
+func (h *GroupHandler) grantRoomInScope(c *gin.Context, roomID uint) bool {
+	if currentBuildingScope(c) == nil {
+		return true
+	}
+
+	var room models.Room
+	if err := h.db.First(&room, roomID).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return false
+	}
+	return h.roomInScope(c, room)
+}
@@ -28,6 +28,7 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 	var logs []models.Log
 
 	query := h.db.Model(&models.Log{}).Preload("Card").Preload("Card.User").Preload("Room")
+	query = currentBuildingScope(c).RoomColumn(h.db, query, "room_id")
 
 	if cardID := c.Query("card_id"); cardID != "" {
 		query = query.Where("card_id = ?", cardID)
@@ -41,6 +42,14 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 		query = query.Where("access_result = ?", result)
 	}
 
+	if direction := c.Query("direction"); direction != "" {
+		query = query.Where("direction = ?", direction)
+	}
+
+	if eventType := c.Query("event_type"); eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+
 	if startDate := c.Query("start_date"); startDate != "" {
 		query = query.Where("timestamp >= ?", startDate+" 00:00:00")
 	}
@@ -87,19 +96,25 @@ func (h *LogHandler) GetLog(c *gin.Context) {
 		return
 	}
 
+	if !currentBuildingScope(c).AllowsRoom(log.Room) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Naplóbejegyzés nem található"})
+		return
+	}
+
 	c.JSON(http.StatusOK, log)
 }
 
 func (h *LogHandler) CreateLog(c *gin.Context) {
 	var input struct {
-		CardID       uint                `json:"card_id" binding:"required"`
-		RoomID       uint                `json:"room_id" binding:"required"`
-		Timestamp    *time.Time          `json:"timestamp"`
-		AccessResult models.AccessResult `json:"access_result" binding:"required"`
-		DenialReason models.DenialReason `json:"denial_reason"`
-		Description  string              `json:"description"`
-		IPAddress    string              `json:"ip_address"`
-		DeviceID     string              `json:"device_id"`
+		CardID       uint                   `json:"card_id" binding:"required"`
+		RoomID       uint                   `json:"room_id" binding:"required"`
+		Timestamp    *time.Time             `json:"timestamp"`
+		AccessResult models.AccessResult    `json:"access_result" binding:"required"`
+		DenialReason models.DenialReason    `json:"denial_reason"`
+		Direction    models.AccessDirection `json:"direction"`
+		Description  string                 `json:"description"`
+		IPAddress    string                 `json:"ip_address"`
+		DeviceID     string                 `json:"device_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -107,6 +122,11 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 		return
 	}
 
+	if !input.Direction.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen irány"})
+		return
+	}
+
 	var card models.Card
 	if err := h.db.First(&card, input.CardID).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen kártya azonosító"})
@@ -119,6 +139,11 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 		return
 	}
 
+	if !currentBuildingScope(c).AllowsRoom(room) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return
+	}
+
 	timestamp := time.Now()
 	if input.Timestamp != nil {
 		timestamp = *input.Timestamp
@@ -130,6 +155,7 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 		Timestamp:    timestamp,
 		AccessResult: input.AccessResult,
 		DenialReason: input.DenialReason,
+		Direction:    input.Direction,
 		Description:  input.Description,
 		IPAddress:    input.IPAddress,
 		DeviceID:     input.DeviceID,
@@ -144,29 +170,30 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 }
 
 func (h *LogHandler) GetRoomStats(c *gin.Context) {
-	endDate := time.Now()
+	var roomID uint
+	if roomIDStr := c.Query("room_id"); roomIDStr != "" {
+		if id, err := strconv.Atoi(roomIDStr); err == nil {
+			roomID = uint(id)
+		}
+	}
+
+	loc := h.statsService.Location(roomID)
+	endDate := time.Now().In(loc)
 	startDate := endDate.AddDate(0, -1, 0)
 
 	if startDateStr := c.Query("start_date"); startDateStr != "" {
-		if t, err := time.Parse("2006-01-02", startDateStr); err == nil {
+		if t, err := time.ParseInLocation("2006-01-02", startDateStr, loc); err == nil {
 			startDate = t
 		}
 	}
 
 	if endDateStr := c.Query("end_date"); endDateStr != "" {
-		if t, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			endDate = t.Add(24*time.Hour - time.Second)
+		if t, err := time.ParseInLocation("2006-01-02", endDateStr, loc); err == nil {
+			endDate = t.AddDate(0, 0, 1).Add(-time.Second)
 		}
 	}
 
-	var roomID uint
-	if roomIDStr := c.Query("room_id"); roomIDStr != "" {
-		if id, err := strconv.Atoi(roomIDStr); err == nil {
-			roomID = uint(id)
-		}
-	}
-
-	stats, err := h.statsService.GetRoomUsageStats(roomID, startDate, endDate)
+	stats, err := h.statsService.Scoped(currentBuildingScope(c)).GetRoomUsageStats(roomID, startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség statisztikák lekérése sikertelen: " + err.Error()})
 		return
@@ -176,18 +203,19 @@ func (h *LogHandler) GetRoomStats(c *gin.Context) {
 }
 
 func (h *LogHandler) GetCardStats(c *gin.Context) {
+	loc := h.statsService.Location(0)
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, -1, 0)
 
 	if startDateStr := c.Query("start_date"); startDateStr != "" {
-		if t, err := time.Parse("2006-01-02", startDateStr); err == nil {
+		if t, err := time.ParseInLocation("2006-01-02", startDateStr, loc); err == nil {
 			startDate = t
 		}
 	}
 
 	if endDateStr := c.Query("end_date"); endDateStr != "" {
-		if t, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			endDate = t.Add(24*time.Hour - time.Second)
+		if t, err := time.ParseInLocation("2006-01-02", endDateStr, loc); err == nil {
+			endDate = t.AddDate(0, 0, 1).Add(-time.Second)
 		}
 	}
 
@@ -198,7 +226,7 @@ func (h *LogHandler) GetCardStats(c *gin.Context) {
 		}
 	}
 
-	stats, err := h.statsService.GetCardUsageStats(cardID, startDate, endDate)
+	stats, err := h.statsService.Scoped(currentBuildingScope(c)).GetCardUsageStats(cardID, startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kártya statisztikák lekérése sikertelen: " + err.Error()})
 		return
@@ -208,31 +236,32 @@ func (h *LogHandler) GetCardStats(c *gin.Context) {
 }
 
 func (h *LogHandler) GetAccessTimeSeries(c *gin.Context) {
-	endDate := time.Now()
+	var roomID uint
+	if roomIDStr := c.Query("room_id"); roomIDStr != "" {
+		if id, err := strconv.Atoi(roomIDStr); err == nil {
+			roomID = uint(id)
+		}
+	}
+
+	loc := h.statsService.Location(roomID)
+	endDate := time.Now().In(loc)
 	startDate := endDate.AddDate(0, 0, -7)
 
 	if startDateStr := c.Query("start_date"); startDateStr != "" {
-		if t, err := time.Parse("2006-01-02", startDateStr); err == nil {
+		if t, err := time.ParseInLocation("2006-01-02", startDateStr, loc); err == nil {
 			startDate = t
 		}
 	}
 
 	if endDateStr := c.Query("end_date"); endDateStr != "" {
-		if t, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			endDate = t.Add(24*time.Hour - time.Second)
-		}
-	}
-
-	var roomID uint
-	if roomIDStr := c.Query("room_id"); roomIDStr != "" {
-		if id, err := strconv.Atoi(roomIDStr); err == nil {
-			roomID = uint(id)
+		if t, err := time.ParseInLocation("2006-01-02", endDateStr, loc); err == nil {
+			endDate = t.AddDate(0, 0, 1).Add(-time.Second)
 		}
 	}
 
 	interval := c.DefaultQuery("interval", "day")
 
-	data, err := h.statsService.GetAccessTimeSeriesData(roomID, interval, startDate, endDate)
+	data, err := h.statsService.Scoped(currentBuildingScope(c)).GetAccessTimeSeriesData(roomID, interval, startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Idősorozat adatok lekérése sikertelen: " + err.Error()})
 		return
@@ -242,18 +271,19 @@ func (h *LogHandler) GetAccessTimeSeries(c *gin.Context) {
 }
 
 func (h *LogHandler) GetMostAccessedRooms(c *gin.Context) {
+	loc := h.statsService.Location(0)
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, -1, 0)
 
 	if startDateStr := c.Query("start_date"); startDateStr != "" {
-		if t, err := time.Parse("2006-01-02", startDateStr); err == nil {
+		if t, err := time.ParseInLocation("2006-01-02", startDateStr, loc); err == nil {
 			startDate = t
 		}
 	}
 
 	if endDateStr := c.Query("end_date"); endDateStr != "" {
-		if t, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			endDate = t.Add(24*time.Hour - time.Second)
+		if t, err := time.ParseInLocation("2006-01-02", endDateStr, loc); err == nil {
+			endDate = t.AddDate(0, 0, 1).Add(-time.Second)
 		}
 	}
 
@@ -264,7 +294,7 @@ func (h *LogHandler) GetMostAccessedRooms(c *gin.Context) {
 		}
 	}
 
-	rooms, err := h.statsService.GetMostAccessedRooms(limit, startDate, endDate)
+	rooms, err := h.statsService.Scoped(currentBuildingScope(c)).GetMostAccessedRooms(limit, startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Leggyakrabban használt helyiségek lekérése sikertelen: " + err.Error()})
 		return
@@ -274,18 +304,19 @@ func (h *LogHandler) GetMostAccessedRooms(c *gin.Context) {
 }
 
 func (h *LogHandler) GetMostActiveUsers(c *gin.Context) {
+	loc := h.statsService.Location(0)
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, -1, 0)
 
 	if startDateStr := c.Query("start_date"); startDateStr != "" {
-		if t, err := time.Parse("2006-01-02", startDateStr); err == nil {
+		if t, err := time.ParseInLocation("2006-01-02", startDateStr, loc); err == nil {
 			startDate = t
 		}
 	}
 
 	if endDateStr := c.Query("end_date"); endDateStr != "" {
-		if t, err := time.Parse("2006-01-02", endDateStr); err == nil {
-			endDate = t.Add(24*time.Hour - time.Second)
+		if t, err := time.ParseInLocation("2006-01-02", endDateStr, loc); err == nil {
+			endDate = t.AddDate(0, 0, 1).Add(-time.Second)
 		}
 	}
 
@@ -296,7 +327,7 @@ func (h *LogHandler) GetMostActiveUsers(c *gin.Context) {
 		}
 	}
 
-	users, err := h.statsService.GetMostActiveUsers(limit, startDate, endDate)
+	users, err := h.statsService.Scoped(currentBuildingScope(c)).GetMostActiveUsers(limit, startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Legaktívabb felhasználók lekérése sikertelen: " + err.Error()})
 		return
@@ -27,7 +27,7 @@ func NewPermissionHandler(db *gorm.DB) *PermissionHandler {
 func (h *PermissionHandler) GetPermissions(c *gin.Context) {
 	var permissions []models.Permission
 
-	query := h.db.Model(&models.Permission{})
+	query := currentBuildingScope(c).RoomColumn(h.db, h.db.Model(&models.Permission{}), "room_id")
 
 	if cardID := c.Query("card_id"); cardID != "" {
 		query = query.Where("card_id = ?", cardID)
@@ -79,6 +79,11 @@ func (h *PermissionHandler) GetPermission(c *gin.Context) {
 		return
 	}
 
+	if !currentBuildingScope(c).AllowsRoom(permission.Room) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Jogosultság nem található"})
+		return
+	}
+
 	c.JSON(http.StatusOK, permission)
 }
 
@@ -87,7 +92,6 @@ func (h *PermissionHandler) CreatePermission(c *gin.Context) {
 		CardID          *uint      `json:"card_id"`
 		UserID          *uint      `json:"user_id"`
 		RoomID          uint       `json:"room_id" binding:"required"`
-		GrantedBy       uint       `json:"granted_by" binding:"required"`
 		ValidFrom       *time.Time `json:"valid_from"`
 		ValidUntil      *time.Time `json:"valid_until"`
 		TimeRestriction string     `json:"time_restriction"`
@@ -125,9 +129,14 @@ func (h *PermissionHandler) CreatePermission(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := h.db.First(&user, input.GrantedBy).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen felhasználó azonosító a jogosultság megadójához"})
+	if !currentBuildingScope(c).AllowsRoom(room) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return
+	}
+
+	schedule, err := models.ParseSchedule(input.TimeRestriction)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen időkorlátozás: " + err.Error()})
 		return
 	}
 
@@ -140,10 +149,10 @@ func (h *PermissionHandler) CreatePermission(c *gin.Context) {
 		CardID:          input.CardID,
 		UserID:          input.UserID,
 		RoomID:          input.RoomID,
-		GrantedBy:       input.GrantedBy,
+		GrantedBy:       currentUserID(c),
 		ValidFrom:       validFrom,
 		ValidUntil:      input.ValidUntil,
-		TimeRestriction: input.TimeRestriction,
+		TimeRestriction: schedule.String(),
 		Active:          true,
 	}
 
@@ -161,26 +170,15 @@ func (h *PermissionHandler) CreatePermission(c *gin.Context) {
 }
 
 func (h *PermissionHandler) UpdatePermission(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen jogosultság azonosító"})
-		return
-	}
-
-	var permission models.Permission
-	if err := h.db.First(&permission, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Jogosultság nem található"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Jogosultság lekérése sikertelen"})
-		}
+	permission, ok := h.findPermission(c)
+	if !ok {
 		return
 	}
 
 	var input struct {
 		ValidFrom       *time.Time `json:"valid_from"`
 		ValidUntil      *time.Time `json:"valid_until"`
-		TimeRestriction string     `json:"time_restriction"`
+		TimeRestriction *string    `json:"time_restriction"`
 		Active          *bool      `json:"active"`
 	}
 
@@ -195,14 +193,19 @@ func (h *PermissionHandler) UpdatePermission(c *gin.Context) {
 	if input.ValidUntil != nil {
 		permission.ValidUntil = input.ValidUntil
 	}
-	if input.TimeRestriction != "" {
-		permission.TimeRestriction = input.TimeRestriction
+	if input.TimeRestriction != nil {
+		schedule, err := models.ParseSchedule(*input.TimeRestriction)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen időkorlátozás: " + err.Error()})
+			return
+		}
+		permission.TimeRestriction = schedule.String()
 	}
 	if input.Active != nil {
 		permission.Active = *input.Active
 	}
 
-	if err := h.db.Save(&permission).Error; err != nil {
+	if err := h.db.Save(permission).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jogosultság frissítése sikertelen"})
 		return
 	}
@@ -211,13 +214,12 @@ func (h *PermissionHandler) UpdatePermission(c *gin.Context) {
 }
 
 func (h *PermissionHandler) DeletePermission(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen jogosultság azonosító"})
+	permission, ok := h.findPermission(c)
+	if !ok {
 		return
 	}
 
-	if err := h.db.Delete(&models.Permission{}, id).Error; err != nil {
+	if err := h.db.Delete(permission).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jogosultság törlése sikertelen"})
 		return
 	}
@@ -226,10 +228,28 @@ func (h *PermissionHandler) DeletePermission(c *gin.Context) {
 }
 
 func (h *PermissionHandler) RevokePermission(c *gin.Context) {
+	permission, ok := h.findPermission(c)
+	if !ok {
+		return
+	}
+
+	permission.Active = false
+
+	if err := h.db.Save(permission).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jogosultság visszavonása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Jogosultság sikeresen visszavonva"})
+}
+
+// findPermission reports permissions of rooms outside the building scope as
+// missing.
+func (h *PermissionHandler) findPermission(c *gin.Context) (*models.Permission, bool) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen jogosultság azonosító"})
-		return
+		return nil, false
 	}
 
 	var permission models.Permission
@@ -239,15 +259,16 @@ func (h *PermissionHandler) RevokePermission(c *gin.Context) {
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Jogosultság lekérése sikertelen"})
 		}
-		return
+		return nil, false
 	}
 
-	permission.Active = false
-
-	if err := h.db.Save(&permission).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jogosultság visszavonása sikertelen"})
-		return
+	if scope := currentBuildingScope(c); scope != nil {
+		var room models.Room
+		if err := h.db.First(&room, permission.RoomID).Error; err != nil || !scope.AllowsRoom(room) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Jogosultság nem található"})
+			return nil, false
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Jogosultság sikeresen visszavonva"})
+	return &permission, true
 }
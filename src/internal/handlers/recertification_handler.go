@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+	"rfid/internal/websocket"
+)
+
+// RecertificationHandler serves recertification campaigns. Campaigns are
+// managed under /api/recertifications, reviewers decide their items under
+// /api/recertification-reviews.
+type RecertificationHandler struct {
+	db              *gorm.DB
+	recertification *utils.RecertificationService
+}
+
+func NewRecertificationHandler(db *gorm.DB) *RecertificationHandler {
+	return &RecertificationHandler{
+		db:              db,
+		recertification: utils.NewRecertificationService(db),
+	}
+}
+
+func (h *RecertificationHandler) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	h.recertification.SetWebSocketHandler(wsHandler)
+}
+
+func (h *RecertificationHandler) CreateCampaign(c *gin.Context) {
+	var input utils.CampaignInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a kampány nevét és határidejét."})
+		return
+	}
+
+	campaign, err := h.recertification.Launch(input, currentUser(c))
+	switch err {
+	case nil:
+	case utils.ErrCampaignTarget, utils.ErrCampaignDeadline, utils.ErrCampaignRoomAbsent:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok: " + err.Error()})
+		return
+	case utils.ErrBuildingOutOfScope:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálati kampány indítása sikertelen"})
+		return
+	}
+
+	var items int64
+	h.db.Model(&models.RecertificationItem{}).Where("campaign_id = ?", campaign.ID).Count(&items)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"campaign": campaign,
+		"items":    items,
+	})
+}
+
+func (h *RecertificationHandler) GetCampaigns(c *gin.Context) {
+	var campaigns []models.RecertificationCampaign
+
+	query := h.db.Model(&models.RecertificationCampaign{})
+
+	if scope := currentBuildingScope(c); scope != nil {
+		query = query.Where("building_id IN ? OR room_id IN (?)", scope.BuildingIDs,
+			h.db.Model(&models.Room{}).Select("id").Where("building_id IN ?", scope.BuildingIDs))
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("created_at DESC").Find(&campaigns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálati kampányok lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaigns)
+}
+
+func (h *RecertificationHandler) GetCampaign(c *gin.Context) {
+	campaign, ok := h.findCampaign(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Preload("Room").Where("campaign_id = ?", campaign.ID).Order("room_id, id").Find(&campaign.Items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálati kampány lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+func (h *RecertificationHandler) GetCampaignReport(c *gin.Context) {
+	campaign, ok := h.findCampaign(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.recertification.Report(campaign.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálati jelentés lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CompleteCampaign closes the campaign before its deadline. Grants nobody
+// reviewed are deactivated just as at the deadline.
+func (h *RecertificationHandler) CompleteCampaign(c *gin.Context) {
+	campaign, ok := h.findCampaign(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.recertification.Complete(campaign.ID)
+	switch err {
+	case nil:
+	case utils.ErrCampaignClosed:
+		c.JSON(http.StatusConflict, gin.H{"error": "A kampány már lezárult"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálati kampány lezárása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Felülvizsgálati kampány lezárva",
+		"report":  report,
+	})
+}
+
+// GetMyReviews lists the pending items of open campaigns the caller can
+// review.
+func (h *RecertificationHandler) GetMyReviews(c *gin.Context) {
+	var items []models.RecertificationItem
+
+	user := currentUser(c)
+	query, err := h.recertification.ExcludeOwn(h.recertification.Reviewable(h.db.Preload("Room"), user), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálandó jogosultságok lekérése sikertelen"})
+		return
+	}
+
+	if campaignID := c.Query("campaign_id"); campaignID != "" {
+		query = query.Where("campaign_id = ?", campaignID)
+	}
+
+	if err := query.Order("campaign_id, room_id, id").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálandó jogosultságok lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *RecertificationHandler) ConfirmReview(c *gin.Context) {
+	h.review(c, models.ReviewConfirmed, "Jogosultság megerősítve")
+}
+
+func (h *RecertificationHandler) RevokeReview(c *gin.Context) {
+	h.review(c, models.ReviewRevoked, "Jogosultság visszavonva")
+}
+
+func (h *RecertificationHandler) review(c *gin.Context, decision models.ReviewDecision, message string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen tétel azonosító"})
+		return
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok"})
+			return
+		}
+	}
+
+	item, err := h.recertification.Review(uint(id), currentUser(c), decision, input.Note)
+	switch err {
+	case nil:
+	case gorm.ErrRecordNotFound, utils.ErrNotApprover:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Felülvizsgálandó jogosultság nem található"})
+		return
+	case utils.ErrCampaignClosed:
+		c.JSON(http.StatusConflict, gin.H{"error": "A kampány már lezárult"})
+		return
+	case utils.ErrReviewDecided:
+		c.JSON(http.StatusConflict, gin.H{"error": "A jogosultságról már döntöttek"})
+		return
+	case utils.ErrReviewOwnGrant:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Saját jogosultságot nem lehet felülvizsgálni"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálat rögzítése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "item": item})
+}
+
+// findCampaign hides campaigns outside the building scope of the caller.
+func (h *RecertificationHandler) findCampaign(c *gin.Context) (*models.RecertificationCampaign, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen kampány azonosító"})
+		return nil, false
+	}
+
+	var campaign models.RecertificationCampaign
+	if err := h.db.First(&campaign, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Felülvizsgálati kampány nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Felülvizsgálati kampány lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	if scope := currentBuildingScope(c); scope != nil {
+		allowed := false
+		if campaign.BuildingID != nil {
+			allowed = scope.AllowsBuilding(*campaign.BuildingID)
+		} else if campaign.RoomID != nil {
+			var room models.Room
+			allowed = h.db.First(&room, *campaign.RoomID).Error == nil && scope.AllowsRoom(room)
+		}
+		if !allowed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Felülvizsgálati kampány nem található"})
+			return nil, false
+		}
+	}
+
+	return &campaign, true
+}
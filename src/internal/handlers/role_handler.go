@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+type RoleHandler struct {
+	db *gorm.DB
+}
+
+func NewRoleHandler(db *gorm.DB) *RoleHandler {
+	return &RoleHandler{
+		db: db,
+	}
+}
+
+func (h *RoleHandler) GetPrivileges(c *gin.Context) {
+	c.JSON(http.StatusOK, models.AllPrivileges)
+}
+
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	var roles []models.Role
+
+	if err := h.db.Order("name").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Szerepkörök lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	h.db.Model(role).Association("Users").Find(&role.Users)
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var input struct {
+		Name        string             `json:"name" binding:"required"`
+		Description string             `json:"description"`
+		Privileges  []models.Privilege `json:"privileges"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a szerepkör nevét."})
+		return
+	}
+
+	if !respondInvalidPrivileges(c, input.Privileges) {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Role{}).Where("name = ?", input.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ilyen nevű szerepkör már létezik"})
+		return
+	}
+
+	role := models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Privileges:  input.Privileges,
+	}
+	if role.Privileges == nil {
+		role.Privileges = []models.Privilege{}
+	}
+
+	if err := h.db.Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Szerepkör létrehozása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Description *string            `json:"description"`
+		Privileges  []models.Privilege `json:"privileges"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok"})
+		return
+	}
+
+	if !respondInvalidPrivileges(c, input.Privileges) {
+		return
+	}
+
+	if input.Description != nil {
+		role.Description = *input.Description
+	}
+	if input.Privileges != nil {
+		role.Privileges = input.Privileges
+	}
+
+	if err := h.db.Save(role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Szerepkör frissítése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	if role.System {
+		c.JSON(http.StatusConflict, gin.H{"error": "Rendszer szerepkör nem törölhető"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Users").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Szerepkör törlése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Szerepkör sikeresen törölve"})
+}
+
+func (h *RoleHandler) AssignUserRole(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		RoleID uint `json:"role_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a szerepkört."})
+		return
+	}
+
+	var role models.Role
+	if err := h.db.First(&role, input.RoleID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen szerepkör azonosító"})
+		return
+	}
+
+	if err := h.db.Model(&role).Association("Users").Append(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Szerepkör hozzárendelése sikertelen"})
+		return
+	}
+
+	h.db.Model(user).Association("Roles").Find(&user.Roles)
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *RoleHandler) RemoveUserRole(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	roleID, err := strconv.Atoi(c.Param("role_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen szerepkör azonosító"})
+		return
+	}
+
+	role := models.Role{ID: uint(roleID)}
+	if err := h.db.Model(&role).Association("Users").Delete(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Szerepkör eltávolítása sikertelen"})
+		return
+	}
+
+	h.db.Model(user).Association("Roles").Find(&user.Roles)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AssignUserBuilding limits the user to the rooms of the given building on
+// top of the buildings already assigned. Delegated administrators can only
+// hand on their own buildings.
+func (h *RoleHandler) AssignUserBuilding(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		BuildingID uint `json:"building_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg az épületet."})
+		return
+	}
+
+	var building models.Building
+	if err := h.db.First(&building, input.BuildingID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen épület azonosító"})
+		return
+	}
+
+	if !currentBuildingScope(c).AllowsBuilding(building.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return
+	}
+
+	if err := h.db.Model(&building).Association("Admins").Append(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület hozzárendelése sikertelen"})
+		return
+	}
+
+	h.db.Model(user).Association("Buildings").Find(&user.Buildings)
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *RoleHandler) RemoveUserBuilding(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	buildingID, err := strconv.Atoi(c.Param("building_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen épület azonosító"})
+		return
+	}
+
+	if !currentBuildingScope(c).AllowsBuilding(uint(buildingID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+		return
+	}
+
+	// A user without buildings manages the whole campus, so only an
+	// unrestricted caller may lift the last one.
+	if currentBuildingScope(c) != nil {
+		var buildings []models.Building
+		if err := h.db.Model(user).Association("Buildings").Find(&buildings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület eltávolítása sikertelen"})
+			return
+		}
+		if len(buildings) == 1 && buildings[0].ID == uint(buildingID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A felhasználó utolsó épülete nem távolítható el, ezzel a teljes campus kezelését kapná meg"})
+			return
+		}
+	}
+
+	building := models.Building{ID: uint(buildingID)}
+	if err := h.db.Model(&building).Association("Admins").Delete(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület eltávolítása sikertelen"})
+		return
+	}
+
+	h.db.Model(user).Association("Buildings").Find(&user.Buildings)
+
+	c.JSON(http.StatusOK, user)
+}
+
+func respondInvalidPrivileges(c *gin.Context, privileges []models.Privilege) bool {
+	for _, privilege := range privileges {
+		if !privilege.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ismeretlen jogosultság: " + string(privilege)})
+			return false
+		}
+	}
+	return true
+}
+
+func (h *RoleHandler) findRole(c *gin.Context) (*models.Role, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen szerepkör azonosító"})
+		return nil, false
+	}
+
+	var role models.Role
+	if err := h.db.First(&role, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Szerepkör nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Szerepkör lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	return &role, true
+}
+
+func (h *RoleHandler) findUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "érvénytelen felhasználó azonosító"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Felhasználó nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Felhasználó lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	return &user, true
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -8,25 +10,41 @@ import (
 	"gorm.io/gorm"
 
 	"rfid/internal/models"
+	"rfid/internal/utils"
+	"rfid/internal/websocket"
 )
 
 type RoomHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	occupancy *utils.OccupancyService
+	doors     *utils.DoorControlService
 }
 
 func NewRoomHandler(db *gorm.DB) *RoomHandler {
-	return &RoomHandler{db: db}
+	return &RoomHandler{
+		db:        db,
+		occupancy: utils.NewOccupancyService(db),
+		doors:     utils.NewDoorControlService(db),
+	}
+}
+
+func (h *RoomHandler) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	h.doors.SetWebSocketHandler(wsHandler)
 }
 
 func (h *RoomHandler) GetRooms(c *gin.Context) {
 	var rooms []models.Room
 
-	query := h.db.Model(&models.Room{})
+	query := currentBuildingScope(c).Rooms(h.db.Model(&models.Room{}))
 
 	if building := c.Query("building"); building != "" {
 		query = query.Where("building = ?", building)
 	}
 
+	if buildingID := c.Query("building_id"); buildingID != "" {
+		query = query.Where("building_id = ?", buildingID)
+	}
+
 	if accessLevel := c.Query("access_level"); accessLevel != "" {
 		query = query.Where("access_level = ?", accessLevel)
 	}
@@ -40,19 +58,8 @@ func (h *RoomHandler) GetRooms(c *gin.Context) {
 }
 
 func (h *RoomHandler) GetRoom(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
-		return
-	}
-
-	var room models.Room
-	if err := h.db.First(&room, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Helyiség nem található"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség lekérése sikertelen"})
-		}
+	room, ok := h.findRoom(c)
+	if !ok {
 		return
 	}
 
@@ -61,15 +68,22 @@ func (h *RoomHandler) GetRoom(c *gin.Context) {
 
 func (h *RoomHandler) CreateRoom(c *gin.Context) {
 	var input struct {
-		Name              string             `json:"name" binding:"required"`
-		Description       string             `json:"description"`
-		Building          string             `json:"building" binding:"required"`
-		RoomNumber        string             `json:"room_number" binding:"required"`
-		AccessLevel       models.AccessLevel `json:"access_level"`
-		Capacity          int                `json:"capacity"`
-		OperatingHours    string             `json:"operating_hours"`
-		OperatingDays     string             `json:"operating_days"`
-		SpecialConditions string             `json:"special_conditions"`
+		Name              string                  `json:"name" binding:"required"`
+		Description       string                  `json:"description"`
+		Building          string                  `json:"building"`
+		BuildingID        *uint                   `json:"building_id"`
+		RoomNumber        string                  `json:"room_number" binding:"required"`
+		AccessLevel       models.AccessLevel      `json:"access_level"`
+		Capacity          int                     `json:"capacity"`
+		OpeningHours      string                  `json:"opening_hours"`
+		WeeklyHours       map[string][]string     `json:"weekly_hours"`
+		Timezone          string                  `json:"timezone"`
+		SpecialConditions string                  `json:"special_conditions"`
+		AntiPassback      models.AntiPassbackMode `json:"anti_passback"`
+		RequiresPIN       bool                    `json:"requires_pin"`
+		DualAuthorization bool                    `json:"dual_authorization"`
+		DualAuthWindow    int                     `json:"dual_authorization_window"`
+		IsMainEntrance    bool                    `json:"is_main_entrance"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -77,16 +91,63 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 
+	if input.Building == "" && input.BuildingID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kérjük, adja meg az épületet"})
+		return
+	}
+
+	if input.Capacity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A férőhely nem lehet negatív"})
+		return
+	}
+
+	if input.DualAuthWindow < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A kétszemélyes belépés időablaka nem lehet negatív"})
+		return
+	}
+
+	if input.AntiPassback == "" {
+		input.AntiPassback = models.AntiPassbackOff
+	}
+	if !input.AntiPassback.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen anti-passback mód. Megengedett értékek: off, soft, hard"})
+		return
+	}
+
+	openingHours, _, err := normalizeOpeningHours(input.OpeningHours, input.WeeklyHours)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen nyitvatartás: " + err.Error()})
+		return
+	}
+
+	if input.Timezone != "" {
+		if _, err := utils.LoadLocation(input.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ismeretlen időzóna: " + input.Timezone})
+			return
+		}
+	}
+
+	building, ok := h.resolveBuilding(c, input.BuildingID, input.Building)
+	if !ok {
+		return
+	}
+
 	room := models.Room{
-		Name:              input.Name,
-		Description:       input.Description,
-		Building:          input.Building,
-		RoomNumber:        input.RoomNumber,
-		AccessLevel:       input.AccessLevel,
-		Capacity:          input.Capacity,
-		OperatingHours:    input.OperatingHours,
-		OperatingDays:     input.OperatingDays,
-		SpecialConditions: input.SpecialConditions,
+		Name:                    input.Name,
+		Description:             input.Description,
+		Building:                building.Name,
+		BuildingID:              &building.ID,
+		RoomNumber:              input.RoomNumber,
+		AccessLevel:             input.AccessLevel,
+		Capacity:                input.Capacity,
+		OpeningHours:            openingHours,
+		Timezone:                input.Timezone,
+		SpecialConditions:       input.SpecialConditions,
+		AntiPassback:            input.AntiPassback,
+		RequiresPIN:             input.RequiresPIN,
+		DualAuthorization:       input.DualAuthorization,
+		DualAuthorizationWindow: input.DualAuthWindow,
+		IsMainEntrance:          input.IsMainEntrance,
 	}
 
 	if room.AccessLevel == "" {
@@ -102,32 +163,28 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 }
 
 func (h *RoomHandler) UpdateRoom(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
-		return
-	}
-
-	var room models.Room
-	if err := h.db.First(&room, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Helyiség nem található"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség lekérése sikertelen"})
-		}
+	room, ok := h.findRoom(c)
+	if !ok {
 		return
 	}
 
 	var input struct {
-		Name              string             `json:"name"`
-		Description       string             `json:"description"`
-		Building          string             `json:"building"`
-		RoomNumber        string             `json:"room_number"`
-		AccessLevel       models.AccessLevel `json:"access_level"`
-		Capacity          *int               `json:"capacity"`
-		OperatingHours    string             `json:"operating_hours"`
-		OperatingDays     string             `json:"operating_days"`
-		SpecialConditions string             `json:"special_conditions"`
+		Name              string                  `json:"name"`
+		Description       string                  `json:"description"`
+		Building          string                  `json:"building"`
+		BuildingID        *uint                   `json:"building_id"`
+		RoomNumber        string                  `json:"room_number"`
+		AccessLevel       models.AccessLevel      `json:"access_level"`
+		Capacity          *int                    `json:"capacity"`
+		OpeningHours      string                  `json:"opening_hours"`
+		WeeklyHours       map[string][]string     `json:"weekly_hours"`
+		Timezone          optionalString          `json:"timezone"`
+		SpecialConditions string                  `json:"special_conditions"`
+		AntiPassback      models.AntiPassbackMode `json:"anti_passback"`
+		RequiresPIN       *bool                   `json:"requires_pin"`
+		DualAuthorization *bool                   `json:"dual_authorization"`
+		DualAuthWindow    *int                    `json:"dual_authorization_window"`
+		IsMainEntrance    *bool                   `json:"is_main_entrance"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -135,14 +192,47 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 		return
 	}
 
+	if input.Capacity != nil && *input.Capacity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A férőhely nem lehet negatív"})
+		return
+	}
+
+	if input.DualAuthWindow != nil && *input.DualAuthWindow < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A kétszemélyes belépés időablaka nem lehet negatív"})
+		return
+	}
+
+	if input.AntiPassback != "" && !input.AntiPassback.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen anti-passback mód. Megengedett értékek: off, soft, hard"})
+		return
+	}
+
+	openingHours, hoursGiven, err := normalizeOpeningHours(input.OpeningHours, input.WeeklyHours)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen nyitvatartás: " + err.Error()})
+		return
+	}
+
+	if input.Timezone.Value != "" {
+		if _, err := utils.LoadLocation(input.Timezone.Value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ismeretlen időzóna: " + input.Timezone.Value})
+			return
+		}
+	}
+
 	if input.Name != "" {
 		room.Name = input.Name
 	}
 	if input.Description != "" {
 		room.Description = input.Description
 	}
-	if input.Building != "" {
-		room.Building = input.Building
+	if input.Building != "" || input.BuildingID != nil {
+		building, ok := h.resolveBuilding(c, input.BuildingID, input.Building)
+		if !ok {
+			return
+		}
+		room.Building = building.Name
+		room.BuildingID = &building.ID
 	}
 	if input.RoomNumber != "" {
 		room.RoomNumber = input.RoomNumber
@@ -153,17 +243,32 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 	if input.Capacity != nil {
 		room.Capacity = *input.Capacity
 	}
-	if input.OperatingHours != "" {
-		room.OperatingHours = input.OperatingHours
+	if hoursGiven {
+		room.OpeningHours = openingHours
 	}
-	if input.OperatingDays != "" {
-		room.OperatingDays = input.OperatingDays
+	if input.Timezone.Set {
+		room.Timezone = input.Timezone.Value
 	}
 	if input.SpecialConditions != "" {
 		room.SpecialConditions = input.SpecialConditions
 	}
+	if input.AntiPassback != "" {
+		room.AntiPassback = input.AntiPassback
+	}
+	if input.RequiresPIN != nil {
+		room.RequiresPIN = *input.RequiresPIN
+	}
+	if input.DualAuthorization != nil {
+		room.DualAuthorization = *input.DualAuthorization
+	}
+	if input.DualAuthWindow != nil {
+		room.DualAuthorizationWindow = *input.DualAuthWindow
+	}
+	if input.IsMainEntrance != nil {
+		room.IsMainEntrance = *input.IsMainEntrance
+	}
 
-	if err := h.db.Save(&room).Error; err != nil {
+	if err := h.db.Save(room).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség frissítése sikertelen"})
 		return
 	}
@@ -172,13 +277,12 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 }
 
 func (h *RoomHandler) DeleteRoom(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
+	room, ok := h.findRoom(c)
+	if !ok {
 		return
 	}
 
-	if err := h.db.Delete(&models.Room{}, id).Error; err != nil {
+	if err := h.db.Delete(room).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség törlése sikertelen"})
 		return
 	}
@@ -187,14 +291,13 @@ func (h *RoomHandler) DeleteRoom(c *gin.Context) {
 }
 
 func (h *RoomHandler) GetRoomPermissions(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
+	room, ok := h.findRoom(c)
+	if !ok {
 		return
 	}
 
 	var permissions []models.Permission
-	if err := h.db.Where("room_id = ?", id).Preload("Card").Preload("Card.User").Find(&permissions).Error; err != nil {
+	if err := h.db.Where("room_id = ?", room.ID).Preload("Card").Preload("Card.User").Find(&permissions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség jogosultságainak lekérése sikertelen"})
 		return
 	}
@@ -203,14 +306,13 @@ func (h *RoomHandler) GetRoomPermissions(c *gin.Context) {
 }
 
 func (h *RoomHandler) GetRoomLogs(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
+	room, ok := h.findRoom(c)
+	if !ok {
 		return
 	}
 
 	var logs []models.Log
-	query := h.db.Where("room_id = ?", id).Preload("Card").Preload("Card.User")
+	query := h.db.Where("room_id = ?", room.ID).Preload("Card").Preload("Card.User")
 
 	if result := c.Query("result"); result != "" {
 		query = query.Where("access_result = ?", result)
@@ -238,3 +340,290 @@ func (h *RoomHandler) GetRoomLogs(c *gin.Context) {
 
 	c.JSON(http.StatusOK, logs)
 }
+
+func (h *RoomHandler) GetRoomOccupancy(c *gin.Context) {
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.occupancy.Status(*room)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség létszámának lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *RoomHandler) ResetRoomOccupancy(c *gin.Context) {
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	if err := h.occupancy.Reset(room.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség létszámának nullázása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Helyiség létszáma nullázva"})
+}
+
+func (h *RoomHandler) UnlockRoom(c *gin.Context) {
+	var input struct {
+		Reason          string `json:"reason" binding:"required"`
+		DurationSeconds int    `json:"duration_seconds"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a nyitás okát."})
+		return
+	}
+
+	if input.DurationSeconds < 0 || input.DurationSeconds > 3600 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A nyitás időtartama 0 és 3600 másodperc között lehet"})
+		return
+	}
+
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.doors.Unlock(*room, currentUserID(c), input.Reason, input.DurationSeconds)
+	h.respondDoorCommand(c, result, err, "Ajtónyitási parancs elküldve")
+}
+
+func (h *RoomHandler) LockRoom(c *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a zárás okát."})
+		return
+	}
+
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.doors.Lock(*room, currentUserID(c), input.Reason)
+	h.respondDoorCommand(c, result, err, "Ajtózárási parancs elküldve")
+}
+
+func (h *RoomHandler) respondDoorCommand(c *gin.Context, result *utils.DoorCommandResult, err error, message string) {
+	switch err {
+	case nil:
+	case utils.ErrDoorControlUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "A távoli ajtóvezérléshez engedélyezett WebSocket kapcsolat szükséges"})
+		return
+	case utils.ErrNoRoomDevices:
+		c.JSON(http.StatusConflict, gin.H{"error": "A helyiségben nincs engedélyezett olvasó"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ajtóvezérlési parancs küldése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "result": result})
+}
+
+func (h *RoomHandler) findRoom(c *gin.Context) (*models.Room, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen helyiség azonosító"})
+		return nil, false
+	}
+
+	var room models.Room
+	if err := h.db.First(&room, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Helyiség nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiség lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	// Rooms of other buildings are hidden from delegated administrators.
+	if !currentBuildingScope(c).AllowsRoom(room) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Helyiség nem található"})
+		return nil, false
+	}
+
+	return &room, true
+}
+
+func (h *RoomHandler) resolveBuilding(c *gin.Context, id *uint, name string) (*models.Building, bool) {
+	building, err := utils.ResolveBuilding(h.db, currentBuildingScope(c), id, name)
+	switch {
+	case err == nil:
+		return building, true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Épület nem található"})
+	case errors.Is(err, utils.ErrBuildingOutOfScope):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Épület lekérése sikertelen"})
+	}
+	return nil, false
+}
+
+func (h *RoomHandler) GetRoomOpeningHours(c *gin.Context) {
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := models.ParseSchedule(room.OpeningHours)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "A helyiség nyitvatartása nem értelmezhető: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"room_id":       room.ID,
+		"opening_hours": room.OpeningHours,
+		"always_open":   schedule.IsEmpty(),
+		"weekly":        schedule.Weekly(),
+	})
+}
+
+// optionalString is a string field of an update that can be cleared: it is
+// Set when the field is present, null and "" both clearing it.
+type optionalString struct {
+	Set   bool
+	Value string
+}
+
+func (s *optionalString) UnmarshalJSON(data []byte) error {
+	s.Set = true
+	if string(data) == "null" {
+		s.Value = ""
+		return nil
+	}
+	return json.Unmarshal(data, &s.Value)
+}
+
+// normalizeOpeningHours validates the opening hours given either in the
+// schedule grammar or as per-weekday window lists and returns them in
+// canonical form. The second result reports whether any was given.
+func normalizeOpeningHours(openingHours string, weekly map[string][]string) (string, bool, error) {
+	var schedule models.Schedule
+	var err error
+
+	switch {
+	case weekly != nil && openingHours != "":
+		return "", false, errors.New("az opening_hours és a weekly_hours egyszerre nem adható meg")
+	case weekly != nil:
+		schedule, err = models.ScheduleFromWeekly(weekly)
+	case openingHours != "":
+		schedule, err = models.ParseSchedule(openingHours)
+	default:
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+	if schedule.IsEmpty() {
+		return "", false, errors.New("legalább egy időablakot meg kell adni, a folyamatos nyitvatartás: daily 00:00-24:00")
+	}
+
+	return schedule.String(), true, nil
+}
+
+func (h *RoomHandler) GetRoomApprovers(c *gin.Context) {
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	var approvers []models.User
+	if err := h.db.Model(room).Association("Approvers").Find(&approvers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jóváhagyók lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, approvers)
+}
+
+func (h *RoomHandler) AddRoomApprover(c *gin.Context) {
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	approver, ok := bindApprover(c, h.db, func(scope *utils.BuildingScope) bool {
+		return scope.AllowsRoom(*room)
+	})
+	if !ok {
+		return
+	}
+
+	if err := h.db.Model(room).Association("Approvers").Append(approver); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jóváhagyó hozzáadása sikertelen"})
+		return
+	}
+
+	h.db.Model(room).Association("Approvers").Find(&room.Approvers)
+
+	c.JSON(http.StatusOK, room)
+}
+
+func (h *RoomHandler) RemoveRoomApprover(c *gin.Context) {
+	room, ok := h.findRoom(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen felhasználó azonosító"})
+		return
+	}
+
+	if err := h.db.Model(room).Association("Approvers").Delete(&models.User{ID: uint(userID)}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jóváhagyó eltávolítása sikertelen"})
+		return
+	}
+
+	h.db.Model(room).Association("Approvers").Find(&room.Approvers)
+
+	c.JSON(http.StatusOK, room)
+}
+
+// bindApprover loads the user named in the body. Visitors cannot approve. A
+// delegated administrator only approves within their own buildings, covers
+// tells whether their scope includes the room or building.
+func bindApprover(c *gin.Context, db *gorm.DB, covers func(scope *utils.BuildingScope) bool) (*models.User, bool) {
+	var input struct {
+		UserID uint `json:"user_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a jóváhagyót."})
+		return nil, false
+	}
+
+	var user models.User
+	if err := db.Where("is_visitor = ?", false).First(&user, input.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen felhasználó azonosító"})
+		return nil, false
+	}
+
+	var buildings []models.Building
+	if err := db.Model(&user).Association("Buildings").Find(&buildings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Jóváhagyó épületeinek lekérése sikertelen"})
+		return nil, false
+	}
+	if !covers(utils.NewBuildingScope(models.User{Buildings: buildings})) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "A jóváhagyó nem kezelheti ezt az épületet"})
+		return nil, false
+	}
+
+	return &user, true
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+	"rfid/internal/websocket"
+)
+
+// SelfServiceHandler serves the /api/me endpoints. Every query is bound to
+// the logged in user, so no privilege is needed.
+type SelfServiceHandler struct {
+	db            *gorm.DB
+	accessControl *utils.AccessControlService
+	userAccess    *utils.UserAccessService
+	wsHandler     *websocket.WebSocketHandler
+	wsEnabled     bool
+}
+
+func NewSelfServiceHandler(db *gorm.DB) *SelfServiceHandler {
+	return &SelfServiceHandler{
+		db:            db,
+		accessControl: utils.NewAccessControlService(db),
+		userAccess:    utils.NewUserAccessService(db),
+		wsEnabled:     false,
+	}
+}
+
+func (h *SelfServiceHandler) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	h.wsHandler = wsHandler
+	h.wsEnabled = (wsHandler != nil)
+}
+
+func (h *SelfServiceHandler) GetMyCards(c *gin.Context) {
+	var cards []models.Card
+	if err := h.db.Where("user_id = ?", currentUserID(c)).Order("id").Find(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kártyák lekérése sikertelen"})
+		return
+	}
+
+	for i := range cards {
+		// IsActive reports a card past its expiry date as expired.
+		cards[i].IsActive()
+	}
+
+	c.JSON(http.StatusOK, cards)
+}
+
+func (h *SelfServiceHandler) ReportCardLost(c *gin.Context) {
+	card, ok := h.findMyCard(c)
+	if !ok {
+		return
+	}
+
+	if card.Status != models.CardStatusActive && card.Status != models.CardStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "A kártya már nem használható, állapota: " + string(card.Status)})
+		return
+	}
+
+	now := time.Now()
+	if err := h.accessControl.ReportLost(card.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kártya zárolása sikertelen"})
+		return
+	}
+
+	card.Status = models.CardStatusBlocked
+	card.LostReportedAt = &now
+
+	if h.wsEnabled {
+		user, _ := c.Get("user")
+		holder, _ := user.(models.User)
+
+		event := map[string]interface{}{
+			"action": "card_reported_lost",
+			"card": map[string]interface{}{
+				"id":              card.ID,
+				"card_id":         card.CardID,
+				"credential_type": card.CredentialType,
+				"status":          card.Status,
+			},
+			"user": map[string]interface{}{
+				"id":   holder.ID,
+				"name": holder.FullName(),
+			},
+		}
+
+		h.wsHandler.GetHub().BroadcastToUser(card.UserID, "card_event", event)
+		h.wsHandler.GetHub().BroadcastToAdmins("card_event", event)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kártya elvesztése rögzítve, a kártya zárolva",
+		"card":    card,
+	})
+}
+
+func (h *SelfServiceHandler) GetMyAccess(c *gin.Context) {
+	at := time.Now()
+	if atStr := c.Query("at"); atStr != "" {
+		t, err := time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen időpont, RFC3339 formátum szükséges"})
+			return
+		}
+		at = t
+	}
+
+	access, err := h.userAccess.EffectiveAccess(currentUserID(c), at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hozzáférések lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, access)
+}
+
+func (h *SelfServiceHandler) GetMyLogs(c *gin.Context) {
+	var logs []models.Log
+
+	query := h.db.Model(&models.Log{}).Preload("Room").
+		Where("card_id IN (?)", h.db.Model(&models.Card{}).Select("id").Where("user_id = ?", currentUserID(c)))
+
+	if result := c.Query("result"); result != "" {
+		query = query.Where("access_result = ?", result)
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("timestamp >= ?", startDate+" 00:00:00")
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("timestamp <= ?", endDate+" 23:59:59")
+	}
+
+	limit := 50
+	page := 0
+	if pageStr := c.Query("page"); pageStr != "" {
+		if pageNum, err := strconv.Atoi(pageStr); err == nil && pageNum > 0 {
+			page = pageNum - 1
+		}
+	}
+
+	if err := query.Order("timestamp DESC").Limit(limit).Offset(page * limit).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Naplóbejegyzések lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+// findMyCard reports cards of other users as missing.
+func (h *SelfServiceHandler) findMyCard(c *gin.Context) (*models.Card, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen kártya azonosító"})
+		return nil, false
+	}
+
+	var card models.Card
+	if err := h.db.Where("user_id = ?", currentUserID(c)).First(&card, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kártya nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Kártya lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	return &card, true
+}
@@ -17,8 +17,10 @@ type SimulationHandler struct {
 }
 
 type SimulateAccessRequest struct {
-	CardID uint `json:"card_id" binding:"required"`
-	RoomID uint `json:"room_id" binding:"required"`
+	CardID    uint                   `json:"card_id" binding:"required"`
+	RoomID    uint                   `json:"room_id" binding:"required"`
+	Direction models.AccessDirection `json:"direction"`
+	PIN       string                 `json:"pin"`
 }
 
 type SimulateAccessResponse struct {
@@ -41,6 +43,11 @@ func (h *SimulationHandler) SimulateAccess(c *gin.Context) {
 		return
 	}
 
+	if !req.Direction.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen irány"})
+		return
+	}
+
 	var card models.Card
 	if result := h.DB.Preload("User").First(&card, req.CardID); result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Kártya nem található"})
@@ -53,7 +60,14 @@ func (h *SimulationHandler) SimulateAccess(c *gin.Context) {
 		return
 	}
 
-	access, denialReason, err := h.accessControlService.CheckAccess(card.CardID, req.RoomID, "simulation")
+	access, denialReason, err := h.accessControlService.Authorize(utils.AccessRequest{
+		CardID:         card.CardID,
+		CredentialType: card.CredentialType,
+		RoomID:         req.RoomID,
+		DeviceID:       "simulation",
+		Direction:      req.Direction,
+		PIN:            req.PIN,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Hiba történt a hozzáférés ellenőrzésekor"})
 		return
@@ -87,6 +101,20 @@ func (h *SimulationHandler) SimulateAccess(c *gin.Context) {
 		reasonText = "Hiba a jogosultság ellenőrzésekor"
 	case models.DenialReasonNoPermission:
 		reasonText = "Nincs jogosultság a helyiséghez"
+	case models.DenialReasonTimeRestricted:
+		reasonText = "A belépés a jogosultság időkorlátozásán kívül történt"
+	case models.DenialReasonAntiPassback:
+		reasonText = "Ismételt belépési kísérlet kilépés nélkül (anti-passback)"
+	case models.DenialReasonCapacityReached:
+		reasonText = "A helyiség elérte a maximális létszámot"
+	case models.DenialReasonPINRequired:
+		reasonText = "A helyiség PIN kódot is kér"
+	case models.DenialReasonInvalidPIN:
+		reasonText = "Hibás PIN kód"
+	case models.DenialReasonPINLocked:
+		reasonText = "A PIN kód túl sok hibás próbálkozás miatt zárolva"
+	case models.DenialReasonDualAuthPending:
+		reasonText = "A belépéshez egy második jogosult személy érintése is szükséges"
 	default:
 		reasonText = "Ismeretlen ok"
 	}
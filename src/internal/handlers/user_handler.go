@@ -8,14 +8,19 @@ import (
 	"gorm.io/gorm"
 
 	"rfid/internal/models"
+	"rfid/internal/utils"
 )
 
 type UserHandler struct {
-	db *gorm.DB
+	db   *gorm.DB
+	pins *utils.PINService
 }
 
 func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+	return &UserHandler{
+		db:   db,
+		pins: utils.NewPINService(db),
+	}
 }
 
 func (h *UserHandler) GetUsers(c *gin.Context) {
@@ -83,7 +88,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		FirstName: input.FirstName,
 		LastName:  input.LastName,
 		Email:     input.Email,
-		IsAdmin:   input.IsAdmin,
+		IsAdmin:   input.IsAdmin && canGrantAdmin(c),
 		Active:    input.Active,
 	}
 
@@ -112,6 +117,12 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Taking over an admin account would grant its rights just as well.
+	if user.IsAdmin && !canGrantAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Adminisztrátor fiókját csak szerepkör-kezelési jogosultsággal lehet módosítani"})
+		return
+	}
+
 	var input struct {
 		Username  string `json:"username"`
 		Password  string `json:"password"`
@@ -142,7 +153,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	if input.Email != "" {
 		user.Email = input.Email
 	}
-	if input.IsAdmin != nil {
+	if input.IsAdmin != nil && canGrantAdmin(c) {
 		user.IsAdmin = *input.IsAdmin
 	}
 	if input.Active != nil {
@@ -174,6 +185,11 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	if user.IsAdmin && !canGrantAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Adminisztrátor fiókját csak szerepkör-kezelési jogosultsággal lehet törölni"})
+		return
+	}
+
 	if err := h.db.Unscoped().Delete(&models.User{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Felhasználó törlése sikertelen"})
 		return
@@ -197,3 +213,60 @@ func (h *UserHandler) GetUserCards(c *gin.Context) {
 
 	c.JSON(http.StatusOK, cards)
 }
+
+func (h *UserHandler) SetUserPIN(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "érvénytelen felhasználó azonosító"})
+		return
+	}
+
+	var input struct {
+		PIN string `json:"pin" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, ellenőrizze a megadott információkat."})
+		return
+	}
+
+	if err := utils.ValidatePIN(input.PIN); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondPINChange(c, h.pins.Set(uint(id), input.PIN), "PIN kód sikeresen beállítva")
+}
+
+func (h *UserHandler) DeleteUserPIN(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "érvénytelen felhasználó azonosító"})
+		return
+	}
+
+	h.respondPINChange(c, h.pins.Clear(uint(id)), "PIN kód sikeresen törölve")
+}
+
+func (h *UserHandler) UnlockUserPIN(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "érvénytelen felhasználó azonosító"})
+		return
+	}
+
+	h.respondPINChange(c, h.pins.Unlock(uint(id)), "PIN kód zárolása feloldva")
+}
+
+func (h *UserHandler) respondPINChange(c *gin.Context, err error, message string) {
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Felhasználó nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "PIN kód módosítása sikertelen"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
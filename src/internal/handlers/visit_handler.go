@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/utils"
+	"rfid/internal/websocket"
+)
+
+// VisitHandler serves visitor pre-registration. Users with the
+// visitors.manage privilege see every visit, others only the visits they
+// host.
+type VisitHandler struct {
+	db     *gorm.DB
+	visits *utils.VisitService
+	access *utils.UserAccessService
+}
+
+func NewVisitHandler(db *gorm.DB) *VisitHandler {
+	return &VisitHandler{
+		db:     db,
+		visits: utils.NewVisitService(db),
+		access: utils.NewUserAccessService(db),
+	}
+}
+
+func (h *VisitHandler) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	h.visits.SetWebSocketHandler(wsHandler)
+}
+
+func (h *VisitHandler) GetVisits(c *gin.Context) {
+	var visits []models.Visit
+
+	query := h.db.Preload("Visitor").Preload("Host").Preload("Card").Preload("Rooms")
+
+	if !currentUserHasPrivilege(c, models.PrivilegeVisitorsManage) {
+		query = query.Where("host_id = ?", currentUserID(c))
+	} else if hostID := c.Query("host_id"); hostID != "" {
+		query = query.Where("host_id = ?", hostID)
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("starts_at DESC").Find(&visits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Látogatások lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, visits)
+}
+
+func (h *VisitHandler) GetVisit(c *gin.Context) {
+	visit, ok := h.findVisit(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+func (h *VisitHandler) CreateVisit(c *gin.Context) {
+	var input utils.VisitRegistration
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a látogató nevét és a látogatás idejét."})
+		return
+	}
+
+	if input.HostID == 0 || !currentUserHasPrivilege(c, models.PrivilegeVisitorsManage) {
+		input.HostID = currentUserID(c)
+	}
+
+	if !h.checkVisitRooms(c, input) {
+		return
+	}
+
+	visit, err := h.visits.Register(input, currentUserID(c))
+	switch err {
+	case nil:
+	case utils.ErrVisitInvalidWindow, utils.ErrVisitHostMissing, utils.ErrVisitRoomMissing:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok: " + err.Error()})
+		return
+	case utils.ErrVisitorEmailTaken:
+		c.JSON(http.StatusConflict, gin.H{"error": "Az e-mail cím már egy nem látogató felhasználóhoz tartozik"})
+		return
+	case utils.ErrCardPoolEmpty:
+		c.JSON(http.StatusConflict, gin.H{"error": "Nincs szabad kártya a látogatói készletben"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Látogatás rögzítése sikertelen"})
+		return
+	}
+
+	h.db.Preload("Visitor").Preload("Host").Preload("Card").Preload("Rooms").First(visit, visit.ID)
+
+	c.JSON(http.StatusCreated, visit)
+}
+
+func (h *VisitHandler) EndVisit(c *gin.Context) {
+	visit, ok := h.findVisit(c)
+	if !ok {
+		return
+	}
+
+	ended, err := h.visits.End(visit.ID)
+	switch err {
+	case nil:
+	case utils.ErrVisitNotOpen:
+		c.JSON(http.StatusConflict, gin.H{"error": "A látogatás már lezárult"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Látogatás lezárása sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ended)
+}
+
+func (h *VisitHandler) GetPoolCards(c *gin.Context) {
+	var cards []models.Card
+
+	query := h.db.Preload("User").Where("pooled = ?", true)
+
+	if c.Query("available") == "true" {
+		query = query.Where("status = ? AND user_id = 0", models.CardStatusPending)
+	}
+
+	if err := query.Order("id").Find(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Látogatói kártyák lekérése sikertelen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cards)
+}
+
+func (h *VisitHandler) AddPoolCard(c *gin.Context) {
+	var input struct {
+		CardID string `json:"card_id" binding:"required"`
+		Label  string `json:"label"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen adatok. Kérjük, adja meg a kártya azonosítóját."})
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Card{}).Where("card_id = ?", input.CardID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Adatbázis hiba történt a kártya ellenőrzése közben."})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kártya azonosító már regisztrálva van."})
+		return
+	}
+
+	card, err := h.visits.AddPoolCard(input.CardID, input.Label)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kártya regisztrálása sikertelen: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, card)
+}
+
+// checkVisitRooms lets the visitor into rooms the host can enter for the
+// whole visit. Any other room is a new grant and needs the privileges of a permission manager
+// within the building scope. Main entrances are added for every visit.
+func (h *VisitHandler) checkVisitRooms(c *gin.Context, input utils.VisitRegistration) bool {
+	if len(input.RoomIDs) == 0 {
+		return true
+	}
+
+	var rooms []models.Room
+	if err := h.db.Where("id IN ?", input.RoomIDs).Find(&rooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Helyiségek lekérése sikertelen"})
+		return false
+	}
+
+	hostAccess, err := h.access.EffectiveAccess(input.HostID, input.StartsAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "A vendéglátó jogosultságainak lekérése sikertelen"})
+		return false
+	}
+
+	hostRooms := make(map[uint]bool)
+	for _, access := range hostAccess {
+		for _, source := range access.Sources {
+			if source.Covers(input.StartsAt, input.EndsAt) {
+				hostRooms[access.Room.ID] = true
+				break
+			}
+		}
+	}
+
+	canGrant := currentUserHasPrivilege(c, models.PrivilegeVisitorsManage) &&
+		currentUserHasPrivilege(c, models.PrivilegePermissionsWrite)
+	scope := currentBuildingScope(c)
+
+	for _, room := range rooms {
+		if room.IsMainEntrance || hostRooms[room.ID] {
+			continue
+		}
+		if !canGrant {
+			c.JSON(http.StatusForbidden, gin.H{"error": "A látogató csak olyan helyiségbe kaphat belépést, ahová a vendéglátó is beléphet: " + room.Name})
+			return false
+		}
+		if scope != nil && !scope.AllowsRoom(room) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Ennek az épületnek a kezeléséhez nincs jogosultsága"})
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *VisitHandler) findVisit(c *gin.Context) (*models.Visit, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Érvénytelen látogatás azonosító"})
+		return nil, false
+	}
+
+	var visit models.Visit
+	if err := h.db.Preload("Visitor").Preload("Host").Preload("Card").Preload("Rooms").First(&visit, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Látogatás nem található"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Látogatás lekérése sikertelen"})
+		}
+		return nil, false
+	}
+
+	if !currentUserHasPrivilege(c, models.PrivilegeVisitorsManage) && visit.HostID != currentUserID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Látogatás nem található"})
+		return nil, false
+	}
+
+	return &visit, true
+}
@@ -7,20 +7,25 @@ import (
 	"gorm.io/gorm"
 
 	"rfid/internal/config"
+	"rfid/internal/utils"
 )
 
 type APIKeyMiddleware struct {
-	db     *gorm.DB
-	config *config.Config
+	db      *gorm.DB
+	config  *config.Config
+	apiKeys *utils.APIKeyService
 }
 
 func NewAPIKeyMiddleware(db *gorm.DB, config *config.Config) *APIKeyMiddleware {
 	return &APIKeyMiddleware{
-		db:     db,
-		config: config,
+		db:      db,
+		config:  config,
+		apiKeys: utils.NewAPIKeyService(db),
 	}
 }
 
+// APIKeyRequired accepts the key only in the X-API-Key header, query strings
+// end up in proxy and access logs.
 func (m *APIKeyMiddleware) APIKeyRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !m.config.APIKeyRequired {
@@ -31,8 +36,28 @@ func (m *APIKeyMiddleware) APIKeyRequired() gin.HandlerFunc {
 		apiKey := c.GetHeader("X-API-Key")
 
 		if apiKey == "" {
-			apiKey = c.Query("api_key")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API kulcs szükséges"})
+			c.Abort()
+			return
+		}
+
+		if m.authenticate(c, apiKey) {
+			c.Next()
 		}
+	}
+}
+
+// ReaderKeyRequired guards the reader endpoints. When keys are required only
+// a key bound to a device is accepted, so the reader is always the one of
+// the key and never one named in the request.
+func (m *APIKeyMiddleware) ReaderKeyRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.config.APIKeyRequired {
+			c.Next()
+			return
+		}
+
+		apiKey := c.GetHeader("X-API-Key")
 
 		if apiKey == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "API kulcs szükséges"})
@@ -40,20 +65,58 @@ func (m *APIKeyMiddleware) APIKeyRequired() gin.HandlerFunc {
 			return
 		}
 
-		validKey := false
-		for _, key := range m.config.APIKeys {
-			if apiKey == key {
-				validKey = true
-				break
-			}
+		if !m.authenticate(c, apiKey) {
+			return
 		}
 
-		if !validKey {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Érvénytelen API kulcs"})
+		if _, bound := c.Get("apiKeyDeviceID"); !bound {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Olvasó kéréshez olvasóhoz kötött API kulcs szükséges"})
 			c.Abort()
 			return
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// APIKeyOptional checks a key only when one is sent, for endpoints shared by
+// readers and dashboard clients.
+func (m *APIKeyMiddleware) APIKeyOptional() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			if m.authenticate(c, apiKey) {
+				c.Next()
+			}
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authenticate stores the key in the context, or aborts the request and
+// reports false.
+func (m *APIKeyMiddleware) authenticate(c *gin.Context, apiKey string) bool {
+	key, err := m.apiKeys.Authenticate(apiKey, c.ClientIP())
+	if err != nil {
+		switch err {
+		case utils.ErrAPIKeyInvalid:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Érvénytelen API kulcs"})
+		case utils.ErrAPIKeyRevoked:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Az API kulcs vissza lett vonva"})
+		case utils.ErrAPIKeyExpired:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Az API kulcs lejárt"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "API kulcs ellenőrzése sikertelen"})
+		}
+		c.Abort()
+		return false
+	}
+
+	c.Set("apiKeyID", key.ID)
+	if key.DeviceID != nil {
+		c.Set("apiKeyDeviceID", *key.DeviceID)
+	}
+
+	return true
+}
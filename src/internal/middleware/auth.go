@@ -85,7 +85,7 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		userID := uint(claims["id"].(float64))
 
 		var user models.User
-		if err := m.db.First(&user, userID).Error; err != nil {
+		if err := m.db.Preload("Roles").Preload("Buildings").First(&user, userID).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Felhasználó nem található"})
 			} else {
@@ -99,6 +99,11 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		if user.IsVisitor {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Látogatói fiókkal nem lehet bejelentkezni"})
+			return
+		}
+
 		c.Set("user", user)
 		c.Set("userID", userID)
 		c.Set("isAdmin", user.IsAdmin)
@@ -123,3 +128,23 @@ func (m *AuthMiddleware) AdminRequired() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequirePrivilege lets the request through when one of the roles of the
+// user, or the admin flag, grants the privilege.
+func (m *AuthMiddleware) RequirePrivilege(privilege models.Privilege) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Hitelesítés szükséges"})
+			return
+		}
+
+		user, ok := value.(models.User)
+		if !ok || !user.HasPrivilege(privilege) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Nincs jogosultsága ehhez a művelethez: " + string(privilege)})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AccessRequestStatus string
+
+const (
+	AccessRequestPending   AccessRequestStatus = "pending"
+	AccessRequestApproved  AccessRequestStatus = "approved"
+	AccessRequestRejected  AccessRequestStatus = "rejected"
+	AccessRequestCancelled AccessRequestStatus = "cancelled"
+)
+
+// AccessRequest is a user's request for access to a room. It is decided by
+// an approver of the room or of its building. An approval creates the
+// Permission of the requester, granted by the approver.
+type AccessRequest struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	RequesterID uint `gorm:"not null;index" json:"requester_id"`
+	Requester   User `gorm:"foreignKey:RequesterID" json:"requester,omitempty"`
+
+	RoomID uint `gorm:"not null;index" json:"room_id"`
+	Room   Room `json:"room,omitempty"`
+
+	ValidFrom     time.Time  `gorm:"not null" json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until"`
+	Justification string     `gorm:"not null" json:"justification"`
+
+	Status       AccessRequestStatus `gorm:"not null;default:'pending';index" json:"status"`
+	DecidedBy    *uint               `json:"decided_by,omitempty"`
+	DecidedAt    *time.Time          `json:"decided_at,omitempty"`
+	DecisionNote string              `json:"decision_note,omitempty"`
+	PermissionID *uint               `json:"permission_id,omitempty"`
+}
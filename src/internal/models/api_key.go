@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey is a hashed key used by readers and integrations. Only the SHA-256
+// hash and a short prefix are stored, the key itself is shown once on
+// creation. A key with a DeviceID may only act as that device.
+type APIKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name    string `gorm:"not null" json:"name"`
+	Prefix  string `gorm:"not null;index" json:"prefix"`
+	KeyHash string `gorm:"uniqueIndex;not null" json:"-"`
+
+	DeviceID *uint   `gorm:"index" json:"device_id,omitempty"`
+	Device   *Device `json:"device,omitempty"`
+
+	CreatedBy  uint       `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    *uint      `json:"revoked_by,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+}
+
+func (k *APIKey) IsUsableAt(t time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+
+	return k.ExpiresAt == nil || t.Before(*k.ExpiresAt)
+}
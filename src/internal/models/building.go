@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Building carries the settings shared by its rooms. Rooms reference it by
+// BuildingID and keep its name in their Building field.
+type Building struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name     string `gorm:"not null;uniqueIndex" json:"name"`
+	Timezone string `json:"timezone,omitempty"`
+
+	Admins []User `gorm:"many2many:user_buildings;" json:"-"`
+
+	// Approvers decide the access requests for every room of the building.
+	Approvers []User `gorm:"many2many:building_approvers;" json:"approvers,omitempty"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const CalendarDateLayout = "2006-01-02"
+
+type CalendarExceptionType string
+
+const (
+	CalendarHoliday      CalendarExceptionType = "holiday"
+	CalendarClosure      CalendarExceptionType = "closure"
+	CalendarExtraOpening CalendarExceptionType = "extra_opening"
+)
+
+func (t CalendarExceptionType) IsValid() bool {
+	return t == CalendarHoliday || t == CalendarClosure || t == CalendarExtraOpening
+}
+
+type CalendarScope string
+
+const (
+	CalendarScopeCampus   CalendarScope = "campus"
+	CalendarScopeBuilding CalendarScope = "building"
+	CalendarScopeRoom     CalendarScope = "room"
+)
+
+func (s CalendarScope) IsValid() bool {
+	return s == CalendarScopeCampus || s == CalendarScopeBuilding || s == CalendarScopeRoom
+}
+
+// Specificity orders the scopes, a room exception overrides a building one,
+// which overrides a campus wide one.
+func (s CalendarScope) Specificity() int {
+	switch s {
+	case CalendarScopeRoom:
+		return 2
+	case CalendarScopeBuilding:
+		return 1
+	}
+	return 0
+}
+
+// CalendarException overrides the weekly opening hours on the days between
+// StartDate and EndDate (inclusive, YYYY-MM-DD). Holidays and closures close
+// the room, extra openings open it. Hours optionally limits the exception to
+// the given windows of the day in the Schedule grammar (e.g. "08:00-12:00").
+type CalendarException struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Type      CalendarExceptionType `gorm:"not null;index" json:"type"`
+	Scope     CalendarScope         `gorm:"not null" json:"scope"`
+	RoomID    *uint                 `gorm:"index" json:"room_id,omitempty"`
+	Room      *Room                 `json:"room,omitempty"`
+	Building  string                `json:"building,omitempty"`
+	StartDate string                `gorm:"not null;index" json:"start_date"`
+	EndDate   string                `gorm:"not null;index" json:"end_date"`
+	Hours     string                `json:"hours,omitempty"`
+	Reason    string                `gorm:"not null" json:"reason"`
+
+	Source      string `gorm:"not null;default:'manual'" json:"source"`
+	ExternalUID string `gorm:"index" json:"external_uid,omitempty"`
+	CreatedBy   uint   `json:"created_by"`
+}
+
+func (e *CalendarException) Closes() bool {
+	return e.Type != CalendarExtraOpening
+}
+
+func (e *CalendarException) Covers(room Room) bool {
+	switch e.Scope {
+	case CalendarScopeCampus:
+		return true
+	case CalendarScopeBuilding:
+		return e.Building == room.Building
+	case CalendarScopeRoom:
+		return e.RoomID != nil && *e.RoomID == room.ID
+	}
+	return false
+}
+
+func (e *CalendarException) CoversDate(date string) bool {
+	return e.StartDate <= date && date <= e.EndDate
+}
+
+// AppliesAt reports whether the exception is in force at t. An exception
+// without hours lasts the whole day, one whose hours no longer parse never
+// applies.
+func (e *CalendarException) AppliesAt(t time.Time) bool {
+	if !e.CoversDate(t.Format(CalendarDateLayout)) {
+		return false
+	}
+	return withinTimeRestriction(e.Hours, t)
+}
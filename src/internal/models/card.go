@@ -16,6 +16,22 @@ const (
 	CardStatusPending CardStatus = "pending"
 )
 
+type CredentialType string
+
+const (
+	CredentialCard      CredentialType = "card"
+	CredentialMobileNFC CredentialType = "mobile_nfc"
+	CredentialPIN       CredentialType = "pin"
+)
+
+func (t CredentialType) IsValid() bool {
+	return t == CredentialCard || t == CredentialMobileNFC || t == CredentialPIN
+}
+
+// Card is one credential of a user: a physical card, a phone NFC credential
+// or a keypad PIN. A user may hold several, each with its own status and
+// expiry. A PIN credential stores the keypad code the user types before the
+// PIN, the PIN itself is only kept hashed on the user (User.PINHash).
 type Card struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -25,12 +41,22 @@ type Card struct {
 	UserID uint `gorm:"not null" json:"user_id"`
 	User   User `json:"user,omitempty"`
 
-	CardID          string     `gorm:"uniqueIndex;not null" json:"card_id"`
-	EncryptedCardID string     `gorm:"not null" json:"-"`
-	Status          CardStatus `gorm:"not null;default:'active'" json:"status"`
-	ExpiryDate      *time.Time `json:"expiry_date"`
-	IssueDate       time.Time  `gorm:"not null" json:"issue_date"`
-	LastUsed        *time.Time `json:"last_used"`
+	CredentialType  CredentialType `gorm:"not null;default:'card';index" json:"credential_type"`
+	Label           string         `json:"label,omitempty"`
+	CardID          string         `gorm:"uniqueIndex;not null" json:"card_id"`
+	EncryptedCardID string         `gorm:"not null" json:"-"`
+	Status          CardStatus     `gorm:"not null;default:'active'" json:"status"`
+	ExpiryDate      *time.Time     `json:"expiry_date"`
+	IssueDate       time.Time      `gorm:"not null" json:"issue_date"`
+	LastUsed        *time.Time     `json:"last_used"`
+
+	// LostReportedAt is set when the holder reports the card lost, which
+	// blocks it. Unblocking clears it.
+	LostReportedAt *time.Time `json:"lost_reported_at,omitempty"`
+
+	// Pooled cards are handed out to visitors. An idle pool card has no
+	// user and stays pending until the next visit.
+	Pooled bool `gorm:"not null;default:false;index" json:"pooled"`
 
 	Permissions []Permission `json:"permissions,omitempty"`
 	Logs        []Log        `json:"logs,omitempty"`
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Device is a registered card reader. A reader is mounted on one side of a
+// single room's door, so it may only ask about that room and, when Direction
+// is set, only in that direction.
+type Device struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	DeviceID    string `gorm:"uniqueIndex;not null" json:"device_id"`
+	Name        string `gorm:"not null" json:"name"`
+	Description string `json:"description"`
+
+	RoomID    uint            `gorm:"not null;index" json:"room_id"`
+	Room      Room            `json:"room,omitempty"`
+	Direction AccessDirection `json:"direction"`
+
+	Enabled bool `gorm:"not null;default:true" json:"enabled"`
+
+	Online          bool       `gorm:"not null;default:false" json:"online"`
+	LastSeenAt      *time.Time `json:"last_seen_at"`
+	LastSeenIP      string     `json:"last_seen_ip,omitempty"`
+	FirmwareVersion string     `json:"firmware_version,omitempty"`
+	UptimeSeconds   int64      `json:"uptime_seconds"`
+	Tampered        bool       `gorm:"not null;default:false" json:"tampered"`
+}
+
+// DeviceHeartbeat is the periodic status report of a reader.
+type DeviceHeartbeat struct {
+	FirmwareVersion string `json:"firmware_version"`
+	UptimeSeconds   int64  `json:"uptime_seconds"`
+	Tampered        bool   `json:"tampered"`
+}
+
+func (d *Device) Serves(roomID uint, direction AccessDirection) bool {
+	if d.RoomID != roomID {
+		return false
+	}
+
+	return d.Direction == "" || direction == "" || d.Direction == direction
+}
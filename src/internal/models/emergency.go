@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EmergencyMode string
+
+const (
+	EmergencyLockdown   EmergencyMode = "lockdown"
+	EmergencyEvacuation EmergencyMode = "evacuation"
+)
+
+func (m EmergencyMode) IsValid() bool {
+	return m == EmergencyLockdown || m == EmergencyEvacuation
+}
+
+type EmergencyScope string
+
+const (
+	EmergencyScopeRoom     EmergencyScope = "room"
+	EmergencyScopeBuilding EmergencyScope = "building"
+	EmergencyScopeCampus   EmergencyScope = "campus"
+)
+
+func (s EmergencyScope) IsValid() bool {
+	return s == EmergencyScopeRoom || s == EmergencyScopeBuilding || s == EmergencyScopeCampus
+}
+
+// EmergencyState is an emergency declared for a room, a building or the whole
+// campus. During a lockdown only members of the responder group get in,
+// during an evacuation every door is open.
+type EmergencyState struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Mode       EmergencyMode  `gorm:"not null" json:"mode"`
+	Scope      EmergencyScope `gorm:"not null" json:"scope"`
+	RoomID     *uint          `json:"room_id,omitempty"`
+	Room       *Room          `json:"room,omitempty"`
+	BuildingID *uint          `gorm:"index" json:"building_id,omitempty"`
+	Building   string         `json:"building,omitempty"`
+
+	ResponderGroupID *uint  `json:"responder_group_id,omitempty"`
+	ResponderGroup   *Group `json:"responder_group,omitempty"`
+
+	Reason      string     `gorm:"not null" json:"reason"`
+	Active      bool       `gorm:"not null;default:true;index" json:"active"`
+	ActivatedBy uint       `json:"activated_by"`
+	ActivatedAt time.Time  `json:"activated_at"`
+	ClearedBy   *uint      `json:"cleared_by,omitempty"`
+	ClearedAt   *time.Time `json:"cleared_at,omitempty"`
+}
+
+func (e *EmergencyState) Covers(room Room) bool {
+	switch e.Scope {
+	case EmergencyScopeCampus:
+		return true
+	case EmergencyScopeBuilding:
+		return e.BuildingID != nil && room.BuildingID != nil && *e.BuildingID == *room.BuildingID
+	case EmergencyScopeRoom:
+		return e.RoomID != nil && *e.RoomID == room.ID
+	}
+	return false
+}
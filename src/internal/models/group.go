@@ -21,3 +21,58 @@ type Group struct {
 	Users []User `gorm:"many2many:user_groups;" json:"users,omitempty"`
 	Rooms []Room `gorm:"many2many:group_rooms;" json:"rooms,omitempty"`
 }
+
+type GroupRoom struct {
+	GroupID   uint      `gorm:"primaryKey" json:"group_id"`
+	RoomID    uint      `gorm:"primaryKey" json:"room_id"`
+	Room      Room      `json:"room,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	GrantedBy       uint       `json:"granted_by"`
+	GrantedAt       *time.Time `json:"granted_at,omitempty"`
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+	TimeRestriction string     `json:"time_restriction"`
+	Active          bool       `gorm:"not null;default:true" json:"active"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy       *uint      `json:"revoked_by,omitempty"`
+}
+
+func (gr *GroupRoom) IsValid(currentTime time.Time) bool {
+	return gr.IsEffective(currentTime) && gr.IsWithinTimeRestriction(currentTime)
+}
+
+func (gr *GroupRoom) IsEffective(currentTime time.Time) bool {
+	if !gr.Active {
+		return false
+	}
+
+	if gr.ValidFrom != nil && currentTime.Before(*gr.ValidFrom) {
+		return false
+	}
+
+	if gr.ValidUntil != nil && currentTime.After(*gr.ValidUntil) {
+		return false
+	}
+
+	return true
+}
+
+func (gr *GroupRoom) InvalidReason(currentTime time.Time) string {
+	switch {
+	case !gr.Active:
+		return ValidityInactive
+	case gr.ValidFrom != nil && currentTime.Before(*gr.ValidFrom):
+		return ValidityNotYetValid
+	case gr.ValidUntil != nil && currentTime.After(*gr.ValidUntil):
+		return ValidityExpired
+	case !gr.IsWithinTimeRestriction(currentTime):
+		return ValidityOutsideTimeRestriction
+	}
+	return ""
+}
+
+func (gr *GroupRoom) IsWithinTimeRestriction(currentTime time.Time) bool {
+	return withinTimeRestriction(gr.TimeRestriction, currentTime)
+}
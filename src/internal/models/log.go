@@ -24,8 +24,42 @@ const (
 	DenialReasonCardBlocked     DenialReason = "card_blocked"
 	DenialReasonCardRevoked     DenialReason = "card_revoked"
 	DenialReasonPermissionError DenialReason = "permission_error"
+	DenialReasonTimeRestricted  DenialReason = "time_restricted"
+	DenialReasonAntiPassback    DenialReason = "anti_passback"
+	DenialReasonCapacityReached DenialReason = "capacity_reached"
+	DenialReasonPINRequired     DenialReason = "pin_required"
+	DenialReasonInvalidPIN      DenialReason = "invalid_pin"
+	DenialReasonPINLocked       DenialReason = "pin_locked"
+	DenialReasonDualAuthPending DenialReason = "dual_auth_pending"
+	DenialReasonUnknownCard     DenialReason = "unknown_credential"
+	DenialReasonTooManyAttempts DenialReason = "too_many_attempts"
 )
 
+type AccessDirection string
+
+const (
+	DirectionEntry AccessDirection = "entry"
+	DirectionExit  AccessDirection = "exit"
+)
+
+func (d AccessDirection) IsValid() bool {
+	return d == "" || d == DirectionEntry || d == DirectionExit
+}
+
+type LogEventType string
+
+const (
+	LogEventAccess       LogEventType = "access"
+	LogEventManualUnlock LogEventType = "manual_unlock"
+	LogEventManualLock   LogEventType = "manual_lock"
+	LogEventLockdown     LogEventType = "emergency_lockdown"
+	LogEventEvacuation   LogEventType = "emergency_evacuation"
+	LogEventEmergencyEnd LogEventType = "emergency_cleared"
+)
+
+// Log records card access decisions, manual door overrides and emergency
+// state changes. The latter have no card (CardID is 0) and carry the acting
+// admin instead.
 type Log struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -38,10 +72,19 @@ type Log struct {
 	RoomID uint `gorm:"not null" json:"room_id"`
 	Room   Room `json:"room,omitempty"`
 
-	Timestamp    time.Time    `gorm:"not null" json:"timestamp"`
-	AccessResult AccessResult `gorm:"not null" json:"access_result"`
-	DenialReason DenialReason `json:"denial_reason,omitempty"`
-	Description  string       `json:"description,omitempty"`
-	IPAddress    string       `json:"ip_address,omitempty"`
-	DeviceID     string       `json:"device_id,omitempty"`
+	Timestamp    time.Time       `gorm:"not null" json:"timestamp"`
+	AccessResult AccessResult    `gorm:"not null" json:"access_result"`
+	DenialReason DenialReason    `json:"denial_reason,omitempty"`
+	Direction    AccessDirection `gorm:"index" json:"direction,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	IPAddress    string          `json:"ip_address,omitempty"`
+	DeviceID     string          `json:"device_id,omitempty"`
+	Offline      bool            `gorm:"not null;default:false;index" json:"offline"`
+
+	EventType   LogEventType `gorm:"not null;default:'access';index" json:"event_type"`
+	ActorUserID *uint        `gorm:"index" json:"actor_user_id,omitempty"`
+
+	// LinkedLogID pairs the two taps of a dual authorisation entry, each
+	// log points to the other.
+	LinkedLogID *uint `gorm:"index" json:"linked_log_id,omitempty"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// RoomOccupancy marks a card as currently inside a room. A row is created by
+// a granted entry and removed by a granted exit.
+type RoomOccupancy struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RoomID uint `gorm:"not null;uniqueIndex:idx_occupancy_room_card" json:"room_id"`
+
+	CardID uint `gorm:"not null;uniqueIndex:idx_occupancy_room_card" json:"card_id"`
+	Card   Card `json:"card,omitempty"`
+
+	UserID    uint      `gorm:"index" json:"user_id"`
+	EnteredAt time.Time `gorm:"not null" json:"entered_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// DeviceAccessList is a stored version of the offline access list of a
+// reader. Older versions are kept so readers can sync with a delta.
+type DeviceAccessList struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	DeviceID uint   `gorm:"not null;uniqueIndex:idx_access_list_device_version" json:"device_id"`
+	Version  int    `gorm:"not null;uniqueIndex:idx_access_list_device_version" json:"version"`
+	Checksum string `gorm:"not null" json:"checksum"`
+	Entries  string `gorm:"type:text;not null" json:"-"`
+}
+
+// OfflineSwipe is a card swipe a reader decided on its own while it could
+// not reach the server.
+type OfflineSwipe struct {
+	CardID       string          `json:"card_id"`
+	Timestamp    time.Time       `json:"timestamp"`
+	Direction    AccessDirection `json:"direction"`
+	Granted      bool            `json:"granted"`
+	DenialReason DenialReason    `json:"reason_code"`
+	ListVersion  int             `json:"list_version"`
+}
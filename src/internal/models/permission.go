@@ -6,6 +6,14 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	ValidityInactive               = "inactive"
+	ValidityNoSubject              = "no_subject"
+	ValidityNotYetValid            = "not_yet_valid"
+	ValidityExpired                = "expired"
+	ValidityOutsideTimeRestriction = "outside_time_restriction"
+)
+
 type Permission struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -26,9 +34,15 @@ type Permission struct {
 	ValidUntil      *time.Time `json:"valid_until"`
 	TimeRestriction string     `json:"time_restriction"`
 	Active          bool       `gorm:"not null;default:true" json:"active"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy       *uint      `json:"revoked_by,omitempty"`
 }
 
 func (p *Permission) IsValid(currentTime time.Time) bool {
+	return p.IsEffective(currentTime) && p.IsWithinTimeRestriction(currentTime)
+}
+
+func (p *Permission) IsEffective(currentTime time.Time) bool {
 	if !p.Active {
 		return false
 	}
@@ -45,10 +59,27 @@ func (p *Permission) IsValid(currentTime time.Time) bool {
 		return false
 	}
 
-	if p.TimeRestriction != "" {
+	return true
+}
+
+func (p *Permission) InvalidReason(currentTime time.Time) string {
+	switch {
+	case !p.Active:
+		return ValidityInactive
+	case p.CardID == nil && p.UserID == nil:
+		return ValidityNoSubject
+	case currentTime.Before(p.ValidFrom):
+		return ValidityNotYetValid
+	case p.ValidUntil != nil && currentTime.After(*p.ValidUntil):
+		return ValidityExpired
+	case !p.IsWithinTimeRestriction(currentTime):
+		return ValidityOutsideTimeRestriction
 	}
+	return ""
+}
 
-	return true
+func (p *Permission) IsWithinTimeRestriction(currentTime time.Time) bool {
+	return withinTimeRestriction(p.TimeRestriction, currentTime)
 }
 
 func (p *Permission) Validate() error {
@@ -60,5 +91,9 @@ func (p *Permission) Validate() error {
 		return gorm.ErrInvalidData
 	}
 
+	if _, err := ParseSchedule(p.TimeRestriction); err != nil {
+		return err
+	}
+
 	return nil
 }
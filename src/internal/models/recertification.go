@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CampaignStatus string
+
+const (
+	CampaignOpen      CampaignStatus = "open"
+	CampaignCompleted CampaignStatus = "completed"
+)
+
+type GrantType string
+
+const (
+	GrantCard  GrantType = "card"
+	GrantUser  GrantType = "user"
+	GrantGroup GrantType = "group"
+)
+
+type ReviewDecision string
+
+const (
+	ReviewPending   ReviewDecision = "pending"
+	ReviewConfirmed ReviewDecision = "confirmed"
+	ReviewRevoked   ReviewDecision = "revoked"
+	// ReviewExpired marks grants deactivated at the deadline because
+	// nobody confirmed them.
+	ReviewExpired ReviewDecision = "expired"
+)
+
+// RecertificationCampaign asks the approvers of a room, or of every room of
+// a building, to confirm or revoke the grants active at launch. Grants still
+// unreviewed at the deadline are deactivated and the campaign completes.
+type RecertificationCampaign struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name       string    `gorm:"not null" json:"name"`
+	RoomID     *uint     `gorm:"index" json:"room_id,omitempty"`
+	BuildingID *uint     `gorm:"index" json:"building_id,omitempty"`
+	Deadline   time.Time `gorm:"not null;index" json:"deadline"`
+	CreatedBy  uint      `json:"created_by"`
+
+	Status      CampaignStatus `gorm:"not null;default:'open';index" json:"status"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+
+	Items []RecertificationItem `gorm:"foreignKey:CampaignID" json:"items,omitempty"`
+}
+
+// RecertificationItem is one grant under review. Card and user grants point
+// at their Permission, group grants at the GroupRoom of GroupID and RoomID
+// as issued at GroupGrantedAt.
+type RecertificationItem struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CampaignID uint `gorm:"not null;index" json:"campaign_id"`
+
+	GrantType      GrantType  `gorm:"not null" json:"grant_type"`
+	PermissionID   *uint      `gorm:"index" json:"permission_id,omitempty"`
+	CardID         *uint      `json:"card_id,omitempty"`
+	UserID         *uint      `json:"user_id,omitempty"`
+	GroupID        *uint      `json:"group_id,omitempty"`
+	GroupGrantedAt *time.Time `json:"group_granted_at,omitempty"`
+	RoomID         uint       `gorm:"not null;index" json:"room_id"`
+	Room           Room       `json:"room,omitempty"`
+
+	// Subject is the holder of the grant as shown to the reviewer.
+	Subject string `json:"subject"`
+
+	Decision  ReviewDecision `gorm:"not null;default:'pending';index" json:"decision"`
+	DecidedBy *uint          `json:"decided_by,omitempty"`
+	DecidedAt *time.Time     `json:"decided_at,omitempty"`
+	Note      string         `json:"note,omitempty"`
+}
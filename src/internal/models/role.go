@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Privilege is a single action a role may allow, checked per API route.
+type Privilege string
+
+const (
+	PrivilegeUsersRead              Privilege = "users.read"
+	PrivilegeUsersWrite             Privilege = "users.write"
+	PrivilegeCardsRead              Privilege = "cards.read"
+	PrivilegeCardsWrite             Privilege = "cards.write"
+	PrivilegeCardsBlock             Privilege = "cards.block"
+	PrivilegeRoomsRead              Privilege = "rooms.read"
+	PrivilegeRoomsWrite             Privilege = "rooms.write"
+	PrivilegeDoorsControl           Privilege = "doors.control"
+	PrivilegeBuildingsRead          Privilege = "buildings.read"
+	PrivilegeBuildingsWrite         Privilege = "buildings.write"
+	PrivilegePermissionsRead        Privilege = "permissions.read"
+	PrivilegePermissionsWrite       Privilege = "permissions.write"
+	PrivilegeLogsRead               Privilege = "logs.read"
+	PrivilegeLogsWrite              Privilege = "logs.write"
+	PrivilegeGroupsRead             Privilege = "groups.read"
+	PrivilegeGroupsWrite            Privilege = "groups.write"
+	PrivilegeDevicesRead            Privilege = "devices.read"
+	PrivilegeDevicesWrite           Privilege = "devices.write"
+	PrivilegeAPIKeysManage          Privilege = "api_keys.manage"
+	PrivilegeEmergenciesRead        Privilege = "emergencies.read"
+	PrivilegeEmergenciesManage      Privilege = "emergencies.manage"
+	PrivilegeCalendarRead           Privilege = "calendar.read"
+	PrivilegeCalendarWrite          Privilege = "calendar.write"
+	PrivilegeAccessExplain          Privilege = "access.explain"
+	PrivilegeAccessSimulate         Privilege = "access.simulate"
+	PrivilegeVisitsHost             Privilege = "visits.host"
+	PrivilegeVisitorsManage         Privilege = "visitors.manage"
+	PrivilegeRolesManage            Privilege = "roles.manage"
+	PrivilegeRecertificationsRead   Privilege = "recertifications.read"
+	PrivilegeRecertificationsManage Privilege = "recertifications.manage"
+)
+
+var AllPrivileges = []Privilege{
+	PrivilegeUsersRead, PrivilegeUsersWrite,
+	PrivilegeCardsRead, PrivilegeCardsWrite, PrivilegeCardsBlock,
+	PrivilegeRoomsRead, PrivilegeRoomsWrite, PrivilegeDoorsControl,
+	PrivilegeBuildingsRead, PrivilegeBuildingsWrite,
+	PrivilegePermissionsRead, PrivilegePermissionsWrite,
+	PrivilegeLogsRead, PrivilegeLogsWrite,
+	PrivilegeGroupsRead, PrivilegeGroupsWrite,
+	PrivilegeDevicesRead, PrivilegeDevicesWrite,
+	PrivilegeAPIKeysManage,
+	PrivilegeEmergenciesRead, PrivilegeEmergenciesManage,
+	PrivilegeCalendarRead, PrivilegeCalendarWrite,
+	PrivilegeAccessExplain, PrivilegeAccessSimulate,
+	PrivilegeVisitsHost, PrivilegeVisitorsManage,
+	PrivilegeRolesManage,
+	PrivilegeRecertificationsRead, PrivilegeRecertificationsManage,
+}
+
+func (p Privilege) IsValid() bool {
+	for _, privilege := range AllPrivileges {
+		if p == privilege {
+			return true
+		}
+	}
+	return false
+}
+
+// Role bundles privileges for non-admin staff. System roles are seeded at
+// startup and cannot be deleted, only edited.
+type Role struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string      `gorm:"uniqueIndex;not null" json:"name"`
+	Description string      `json:"description"`
+	Privileges  []Privilege `gorm:"serializer:json" json:"privileges"`
+	System      bool        `gorm:"not null;default:false" json:"system"`
+
+	Users []User `gorm:"many2many:user_roles;" json:"users,omitempty"`
+}
+
+func (r *Role) Allows(privilege Privilege) bool {
+	for _, p := range r.Privileges {
+		if p == privilege {
+			return true
+		}
+	}
+	return false
+}
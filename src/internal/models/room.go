@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,6 +16,18 @@ const (
 	AccessLevelAdmin      AccessLevel = "admin"
 )
 
+type AntiPassbackMode string
+
+const (
+	AntiPassbackOff  AntiPassbackMode = "off"
+	AntiPassbackSoft AntiPassbackMode = "soft"
+	AntiPassbackHard AntiPassbackMode = "hard"
+)
+
+func (m AntiPassbackMode) IsValid() bool {
+	return m == AntiPassbackOff || m == AntiPassbackSoft || m == AntiPassbackHard
+}
+
 type Room struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -24,50 +37,102 @@ type Room struct {
 	Name        string      `gorm:"not null" json:"name"`
 	Description string      `json:"description"`
 	Building    string      `gorm:"not null" json:"building"`
+	BuildingID  *uint       `gorm:"index" json:"building_id"`
 	RoomNumber  string      `gorm:"not null" json:"room_number"`
 	AccessLevel AccessLevel `gorm:"not null;default:'restricted'" json:"access_level"`
 	Capacity    int         `json:"capacity"`
 
-	OperatingHours    string `json:"operating_hours"`
-	OperatingDays     string `json:"operating_days"`
+	// OpeningHours uses the Schedule grammar, e.g.
+	// "mon-fri 08:00-12:00,13:00-17:00; sat 22:00-06:00". Empty means the
+	// room is always open.
+	OpeningHours      string `json:"opening_hours"`
 	SpecialConditions string `json:"special_conditions"`
 
+	// Timezone overrides the zone of the building, opening hours, calendar
+	// exceptions and permission windows are evaluated in it.
+	Timezone string `json:"timezone,omitempty"`
+
+	AntiPassback AntiPassbackMode `gorm:"not null;default:'off'" json:"anti_passback"`
+
+	// RequiresPIN asks for the PIN of the card holder on top of the card.
+	RequiresPIN bool `gorm:"not null;default:false" json:"requires_pin"`
+
+	// DualAuthorization only opens the door when two different authorised
+	// users tap within DualAuthorizationWindow seconds.
+	DualAuthorization       bool `gorm:"not null;default:false" json:"dual_authorization"`
+	DualAuthorizationWindow int  `json:"dual_authorization_window,omitempty"`
+
+	// IsMainEntrance rooms are opened for every visitor, and a visitor
+	// badging in there is reported to the host.
+	IsMainEntrance bool `gorm:"not null;default:false" json:"is_main_entrance"`
+
+	// Approvers decide the access requests for the room, next to the
+	// approvers of its building.
+	Approvers []User `gorm:"many2many:room_approvers;" json:"approvers,omitempty"`
+
 	Permissions []Permission `json:"permissions,omitempty"`
 	Logs        []Log        `json:"logs,omitempty"`
 }
 
+const DefaultDualAuthorizationWindow = 30
+
+func (r *Room) DualAuthorizationTimeout() time.Duration {
+	if r.DualAuthorizationWindow <= 0 {
+		return DefaultDualAuthorizationWindow * time.Second
+	}
+	return time.Duration(r.DualAuthorizationWindow) * time.Second
+}
+
 func (r *Room) IsAccessibleWithoutCard() bool {
 	return r.AccessLevel == AccessLevelPublic
 }
 
+// IsAccessibleAtTime evaluates the opening hours in t's location. Hours that
+// no longer parse keep the room closed.
 func (r *Room) IsAccessibleAtTime(t time.Time) bool {
-	day := int(t.Weekday())
-	dayStr := string('0' + rune(day))
-	if r.OperatingDays != "" && !contains(r.OperatingDays, dayStr) {
-		return false
-	}
+	return withinTimeRestriction(r.OpeningHours, t)
+}
 
-	if r.OperatingHours != "" {
-		var openHour, openMin, closeHour, closeMin int
-		_, err := fmt.Sscanf(r.OperatingHours, "%d:%d-%d:%d", &openHour, &openMin, &closeHour, &closeMin)
-		if err == nil {
-			openTime := time.Date(t.Year(), t.Month(), t.Day(), openHour, openMin, 0, 0, t.Location())
-			closeTime := time.Date(t.Year(), t.Month(), t.Day(), closeHour, closeMin, 0, 0, t.Location())
+// LegacyOpeningHours converts the former OperatingHours ("08:00-18:00") and
+// OperatingDays ("1,2,3,4,5", 0 is Sunday) pair into the Schedule grammar.
+// A window whose end is before its start becomes an overnight window. The
+// error reports hours that never parsed, in which case the days are kept
+// open all day as they were before.
+func LegacyOpeningHours(hours, days string) (string, error) {
+	if hours == "" && days == "" {
+		return "", nil
+	}
 
-			if t.Before(openTime) || t.After(closeTime) {
-				return false
+	rule := ScheduleRule{Days: append([]time.Weekday(nil), weekdayOrder...)}
+	if days != "" {
+		rule.Days = nil
+		for _, day := range weekdayOrder {
+			if strings.ContainsRune(days, rune('0'+int(day))) {
+				rule.Days = append(rule.Days, day)
 			}
 		}
+		if len(rule.Days) == 0 {
+			return "", fmt.Errorf("érvénytelen nyitvatartási napok: %q", days)
+		}
 	}
 
-	return true
-}
+	allDay := TimeWindow{Start: 0, End: 24 * 60}
 
-func contains(s, substr string) bool {
-	for i := 0; i < len(s); i++ {
-		if string(s[i]) == substr {
-			return true
+	var err error
+	if hours == "" {
+		rule.Windows = []TimeWindow{allDay}
+	} else {
+		var openHour, openMin, closeHour, closeMin int
+		if _, scanErr := fmt.Sscanf(hours, "%d:%d-%d:%d", &openHour, &openMin, &closeHour, &closeMin); scanErr != nil {
+			err = fmt.Errorf("érvénytelen nyitvatartási idő: %q", hours)
+			rule.Windows = []TimeWindow{allDay}
+		} else if window, parseErr := parseTimeWindow(fmt.Sprintf("%02d:%02d-%02d:%02d", openHour, openMin, closeHour, closeMin)); parseErr != nil {
+			err = parseErr
+			rule.Windows = []TimeWindow{allDay}
+		} else {
+			rule.Windows = []TimeWindow{window}
 		}
 	}
-	return false
+
+	return Schedule{Rules: []ScheduleRule{rule}}.String(), err
 }
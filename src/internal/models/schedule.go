@@ -0,0 +1,377 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is the parsed form of a weekly time schedule such as
+// "mon-fri 08:00-16:00; sat 09:00-12:00,13:00-15:00; fri 22:00-06:00".
+// Rules are separated by ";", a rule is an optional day list followed by one
+// or more comma separated HH:MM-HH:MM windows. A window whose end is earlier
+// than its start runs overnight into the following day. A rule without days
+// applies to every day.
+type Schedule struct {
+	Rules []ScheduleRule `json:"rules"`
+}
+
+type ScheduleRule struct {
+	Days    []time.Weekday `json:"days"`
+	Windows []TimeWindow   `json:"windows"`
+}
+
+// TimeWindow holds the start and end of a window in minutes since midnight.
+type TimeWindow struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+var weekdayOrder = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+func ParseSchedule(value string) (Schedule, error) {
+	var schedule Schedule
+
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		rule, err := parseScheduleRule(part)
+		if err != nil {
+			return Schedule{}, err
+		}
+		schedule.Rules = append(schedule.Rules, rule)
+	}
+
+	return schedule, nil
+}
+
+func parseScheduleRule(value string) (ScheduleRule, error) {
+	fields := strings.Fields(strings.ToLower(value))
+
+	var rule ScheduleRule
+	windowFields := fields
+
+	if first := fields[0]; first == "*" || first == "daily" || (first[0] >= 'a' && first[0] <= 'z') {
+		days, err := parseDays(first)
+		if err != nil {
+			return ScheduleRule{}, err
+		}
+		rule.Days = days
+		windowFields = fields[1:]
+	} else {
+		rule.Days = append([]time.Weekday(nil), weekdayOrder...)
+	}
+
+	if len(windowFields) == 0 {
+		return ScheduleRule{}, fmt.Errorf("hiányzó időablak: %q", value)
+	}
+
+	for _, item := range strings.Split(strings.Join(windowFields, ""), ",") {
+		if item == "" {
+			continue
+		}
+
+		window, err := parseTimeWindow(item)
+		if err != nil {
+			return ScheduleRule{}, err
+		}
+		rule.Windows = append(rule.Windows, window)
+	}
+
+	if len(rule.Windows) == 0 {
+		return ScheduleRule{}, fmt.Errorf("hiányzó időablak: %q", value)
+	}
+
+	return rule, nil
+}
+
+func parseDays(value string) ([]time.Weekday, error) {
+	if value == "*" || value == "daily" {
+		return append([]time.Weekday(nil), weekdayOrder...), nil
+	}
+
+	seen := make(map[time.Weekday]bool)
+	var days []time.Weekday
+
+	add := func(day time.Weekday) {
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		if item == "" {
+			continue
+		}
+
+		if from, to, isRange := strings.Cut(item, "-"); isRange {
+			start, ok := weekdayNames[from]
+			if !ok {
+				return nil, fmt.Errorf("érvénytelen nap: %q", from)
+			}
+			end, ok := weekdayNames[to]
+			if !ok {
+				return nil, fmt.Errorf("érvénytelen nap: %q", to)
+			}
+
+			for day := start; ; day = (day + 1) % 7 {
+				add(day)
+				if day == end {
+					break
+				}
+			}
+			continue
+		}
+
+		day, ok := weekdayNames[item]
+		if !ok {
+			return nil, fmt.Errorf("érvénytelen nap: %q", item)
+		}
+		add(day)
+	}
+
+	if len(days) == 0 {
+		return nil, fmt.Errorf("hiányzó napok: %q", value)
+	}
+
+	return days, nil
+}
+
+func parseTimeWindow(value string) (TimeWindow, error) {
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("érvénytelen időablak: %q", value)
+	}
+
+	start, err := parseClock(from)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+
+	if start == 24*60 {
+		return TimeWindow{}, fmt.Errorf("az időablak nem kezdődhet 24:00-kor: %q", value)
+	}
+	if start == end {
+		return TimeWindow{}, fmt.Errorf("az időablak kezdete és vége nem egyezhet: %q", value)
+	}
+
+	return TimeWindow{Start: start, End: end}, nil
+}
+
+func parseClock(value string) (int, error) {
+	hourStr, minuteStr, ok := strings.Cut(value, ":")
+	if !ok || len(minuteStr) != 2 {
+		return 0, fmt.Errorf("érvénytelen időpont: %q", value)
+	}
+
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil {
+		return 0, fmt.Errorf("érvénytelen időpont: %q", value)
+	}
+	minute, err := strconv.Atoi(minuteStr)
+	if err != nil {
+		return 0, fmt.Errorf("érvénytelen időpont: %q", value)
+	}
+
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("érvénytelen időpont: %q", value)
+	}
+
+	return hour*60 + minute, nil
+}
+
+// withinTimeRestriction fails closed: a restriction that no longer parses
+// never matches.
+func withinTimeRestriction(restriction string, t time.Time) bool {
+	if restriction == "" {
+		return true
+	}
+
+	schedule, err := ParseSchedule(restriction)
+	if err != nil {
+		return false
+	}
+
+	return schedule.Contains(t)
+}
+
+func (s Schedule) IsEmpty() bool {
+	return len(s.Rules) == 0
+}
+
+// Contains reports whether t falls into the schedule, evaluated in t's own
+// location. Windows are half-open: "08:00-16:00" admits 08:00:00 but no
+// longer 16:00:00, so adjacent windows such as "08:00-12:00,12:00-16:00"
+// neither overlap nor leave a gap.
+func (s Schedule) Contains(t time.Time) bool {
+	if s.IsEmpty() {
+		return true
+	}
+
+	seconds := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, rule := range s.Rules {
+		for _, window := range rule.Windows {
+			if window.End > window.Start {
+				if rule.hasDay(today) && seconds >= window.Start*60 && seconds < window.End*60 {
+					return true
+				}
+				continue
+			}
+
+			if rule.hasDay(today) && seconds >= window.Start*60 {
+				return true
+			}
+			if rule.hasDay(yesterday) && seconds < window.End*60 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (r ScheduleRule) hasDay(day time.Weekday) bool {
+	for _, d := range r.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Schedule) String() string {
+	rules := make([]string, 0, len(s.Rules))
+
+	for _, rule := range s.Rules {
+		windows := make([]string, 0, len(rule.Windows))
+		for _, window := range rule.Windows {
+			windows = append(windows, formatClock(window.Start)+"-"+formatClock(window.End))
+		}
+
+		if len(rule.Days) == 7 {
+			rules = append(rules, "daily "+strings.Join(windows, ","))
+		} else {
+			rules = append(rules, formatDays(rule.Days)+" "+strings.Join(windows, ","))
+		}
+	}
+
+	return strings.Join(rules, "; ")
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func formatDays(days []time.Weekday) string {
+	included := make(map[time.Weekday]bool)
+	for _, d := range days {
+		included[d] = true
+	}
+
+	dayName := func(day time.Weekday) string {
+		return strings.ToLower(day.String()[:3])
+	}
+
+	var parts []string
+	for i := 0; i < len(weekdayOrder); i++ {
+		if !included[weekdayOrder[i]] {
+			continue
+		}
+
+		j := i
+		for j+1 < len(weekdayOrder) && included[weekdayOrder[j+1]] {
+			j++
+		}
+
+		switch {
+		case j-i >= 2:
+			parts = append(parts, dayName(weekdayOrder[i])+"-"+dayName(weekdayOrder[j]))
+		case j > i:
+			parts = append(parts, dayName(weekdayOrder[i]), dayName(weekdayOrder[j]))
+		default:
+			parts = append(parts, dayName(weekdayOrder[i]))
+		}
+		i = j
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Weekly lists the windows of the schedule per weekday, keyed by the short
+// day names. Overnight windows are listed on the day they start.
+func (s Schedule) Weekly() map[string][]string {
+	weekly := make(map[string][]string)
+
+	for _, day := range weekdayOrder {
+		name := strings.ToLower(day.String()[:3])
+		for _, rule := range s.Rules {
+			if !rule.hasDay(day) {
+				continue
+			}
+			for _, window := range rule.Windows {
+				weekly[name] = append(weekly[name], formatClock(window.Start)+"-"+formatClock(window.End))
+			}
+		}
+	}
+
+	return weekly
+}
+
+// ScheduleFromWeekly builds a schedule from per-weekday window lists such as
+// {"mon": ["08:00-12:00", "13:00-17:00"], "sat": ["22:00-06:00"]}.
+func ScheduleFromWeekly(weekly map[string][]string) (Schedule, error) {
+	normalized := make(map[string][]string, len(weekly))
+	for name, windows := range weekly {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := weekdayNames[name]; !ok {
+			return Schedule{}, fmt.Errorf("érvénytelen nap: %q", name)
+		}
+		normalized[name] = append(normalized[name], windows...)
+	}
+
+	var schedule Schedule
+
+	for _, day := range weekdayOrder {
+		name := strings.ToLower(day.String()[:3])
+		windows, ok := normalized[name]
+		if !ok || len(windows) == 0 {
+			continue
+		}
+
+		rule := ScheduleRule{Days: []time.Weekday{day}}
+		for _, item := range windows {
+			window, err := parseTimeWindow(strings.ReplaceAll(item, " ", ""))
+			if err != nil {
+				return Schedule{}, err
+			}
+			rule.Windows = append(rule.Windows, window)
+		}
+		schedule.Rules = append(schedule.Rules, rule)
+	}
+
+	return schedule, nil
+}
@@ -21,11 +21,27 @@ type User struct {
 	IsAdmin   bool   `gorm:"not null;default:false" json:"is_admin"`
 	Active    bool   `gorm:"not null;default:true" json:"active"`
 
+	// IsVisitor marks users created for a Visit. They only hold pool cards
+	// and cannot log in.
+	IsVisitor bool `gorm:"not null;default:false;index" json:"is_visitor"`
+
+	// PINHash is the bcrypt hash of the second factor asked for by rooms
+	// with RequiresPIN. Wrong PINs are counted and lock the PIN for a while.
+	PINHash           string     `json:"-"`
+	FailedPINAttempts int        `gorm:"not null;default:0" json:"-"`
+	PINLockedUntil    *time.Time `json:"pin_locked_until,omitempty"`
+
 	Cards []Card `json:"cards,omitempty"`
 
 	Permissions []Permission `json:"permissions,omitempty"`
 
 	Groups []Group `gorm:"many2many:user_groups;" json:"groups,omitempty"`
+
+	Roles []Role `gorm:"many2many:user_roles;" json:"roles,omitempty"`
+
+	// Buildings limits a delegated administrator to the rooms of these
+	// buildings. Without any the user is not restricted.
+	Buildings []Building `gorm:"many2many:user_buildings;" json:"buildings,omitempty"`
 }
 
 func (u *User) BeforeSave(tx *gorm.DB) error {
@@ -44,10 +60,45 @@ func (u *User) CheckPassword(password string) bool {
 	return err == nil
 }
 
+func (u *User) HasPIN() bool {
+	return u.PINHash != ""
+}
+
+func (u *User) CheckPIN(pin string) bool {
+	return u.HasPIN() && bcrypt.CompareHashAndPassword([]byte(u.PINHash), []byte(pin)) == nil
+}
+
+func (u *User) PINLockedAt(t time.Time) bool {
+	return u.PINLockedUntil != nil && t.Before(*u.PINLockedUntil)
+}
+
 func (u *User) FullName() string {
 	return u.FirstName + " " + u.LastName
 }
 
+// HasPrivilege needs the roles preloaded. Admins hold every privilege.
+func (u *User) HasPrivilege(privilege Privilege) bool {
+	if u.IsAdmin {
+		return true
+	}
+	for _, role := range u.Roles {
+		if role.Allows(privilege) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) Privileges() []Privilege {
+	privileges := []Privilege{}
+	for _, privilege := range AllPrivileges {
+		if u.HasPrivilege(privilege) {
+			privileges = append(privileges, privilege)
+		}
+	}
+	return privileges
+}
+
 func (u *User) CanAccessDashboard() bool {
-	return u.IsAdmin
+	return u.IsAdmin || len(u.Roles) > 0
 }
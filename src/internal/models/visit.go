@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type VisitStatus string
+
+const (
+	VisitScheduled VisitStatus = "scheduled"
+	VisitCheckedIn VisitStatus = "checked_in"
+	VisitEnded     VisitStatus = "ended"
+	VisitCancelled VisitStatus = "cancelled"
+)
+
+// Visit is a pre-registered stay of a visitor. While it is open the visitor
+// holds a card from the pool with permissions for the listed rooms, valid
+// between StartsAt and EndsAt.
+type Visit struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	VisitorID uint `gorm:"not null;index" json:"visitor_id"`
+	Visitor   User `gorm:"foreignKey:VisitorID" json:"visitor,omitempty"`
+
+	HostID uint `gorm:"not null;index" json:"host_id"`
+	Host   User `gorm:"foreignKey:HostID" json:"host,omitempty"`
+
+	CardID *uint `gorm:"index" json:"card_id,omitempty"`
+	Card   *Card `json:"card,omitempty"`
+
+	Company  string    `json:"company,omitempty"`
+	Purpose  string    `json:"purpose,omitempty"`
+	StartsAt time.Time `gorm:"not null" json:"starts_at"`
+	EndsAt   time.Time `gorm:"not null;index" json:"ends_at"`
+
+	Status      VisitStatus `gorm:"not null;default:'scheduled';index" json:"status"`
+	CheckedInAt *time.Time  `json:"checked_in_at,omitempty"`
+	EndedAt     *time.Time  `json:"ended_at,omitempty"`
+	CreatedBy   uint        `json:"created_by"`
+
+	Rooms []Room `gorm:"many2many:visit_rooms;" json:"rooms,omitempty"`
+}
+
+func (v *Visit) IsOpen() bool {
+	return v.Status == VisitScheduled || v.Status == VisitCheckedIn
+}
@@ -7,6 +7,8 @@ import (
 	"rfid/internal/config"
 	"rfid/internal/handlers"
 	"rfid/internal/middleware"
+	"rfid/internal/models"
+	"rfid/internal/utils"
 	"rfid/internal/websocket"
 )
 
@@ -21,14 +23,51 @@ func SetupRouter(db *gorm.DB, config *config.Config) *gin.Engine {
 	logHandler := handlers.NewLogHandler(db)
 	simulationHandler := handlers.NewSimulationHandler(db)
 	groupHandler := handlers.NewGroupHandler(db)
+	accessHandler := handlers.NewAccessHandler(db)
+	deviceHandler := handlers.NewDeviceHandler(db)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db)
+	emergencyHandler := handlers.NewEmergencyHandler(db)
+	calendarHandler := handlers.NewCalendarHandler(db)
+	buildingHandler := handlers.NewBuildingHandler(db)
+	visitHandler := handlers.NewVisitHandler(db)
+	roleHandler := handlers.NewRoleHandler(db)
+	selfServiceHandler := handlers.NewSelfServiceHandler(db)
+	accessRequestHandler := handlers.NewAccessRequestHandler(db)
+	recertificationHandler := handlers.NewRecertificationHandler(db)
+
+	deviceMonitor := utils.NewDeviceMonitor(db, config.ReaderOfflineThreshold)
+	deviceHandler.SetDeviceMonitor(deviceMonitor)
+
+	offlineAccess := utils.NewOfflineAccessService(db, config.AccessListSigningKey)
+	deviceHandler.SetOfflineAccessService(offlineAccess)
+
+	visitExpiry := utils.NewVisitService(db)
+	recertificationExpiry := utils.NewRecertificationService(db)
 
 	var wsHandler *websocket.WebSocketHandler
 	if config.EnableWebsocket {
 		wsHandler = websocket.NewWebSocketHandler(db)
 
 		cardHandler.SetWebSocketHandler(wsHandler)
+		roomHandler.SetWebSocketHandler(wsHandler)
+		deviceHandler.SetWebSocketHandler(wsHandler)
+		emergencyHandler.SetWebSocketHandler(wsHandler)
+		deviceMonitor.SetWebSocketHandler(wsHandler)
+		visitHandler.SetWebSocketHandler(wsHandler)
+		selfServiceHandler.SetWebSocketHandler(wsHandler)
+		accessRequestHandler.SetWebSocketHandler(wsHandler)
+		recertificationHandler.SetWebSocketHandler(wsHandler)
+		recertificationExpiry.SetWebSocketHandler(wsHandler)
+
+		readerService := utils.NewReaderService(db, deviceMonitor, offlineAccess)
+		readerService.SetWebSocketHandler(wsHandler)
+		wsHandler.SetReaderBackend(readerService)
 	}
 
+	go deviceMonitor.Run()
+	go visitExpiry.Run()
+	go recertificationExpiry.Run()
+
 	authMiddleware := middleware.NewAuthMiddleware(db)
 	apiKeyMiddleware := middleware.NewAPIKeyMiddleware(db, config)
 
@@ -47,13 +86,13 @@ func SetupRouter(db *gorm.DB, config *config.Config) *gin.Engine {
 	})
 
 	if config.EnableWebsocket {
-		router.GET("/ws", wsHandler.HandleWebSocket)
+		router.GET("/ws", apiKeyMiddleware.APIKeyOptional(), wsHandler.HandleWebSocket)
 	}
 
 	auth := router.Group("/api/auth")
 	{
 		auth.POST("/login", authHandler.Login)
-		auth.POST("/register", authMiddleware.AuthRequired(), authMiddleware.AdminRequired(), authHandler.Register)
+		auth.POST("/register", authMiddleware.AuthRequired(), authMiddleware.RequirePrivilege(models.PrivilegeUsersWrite), authHandler.Register)
 		auth.GET("/me", authMiddleware.AuthRequired(), authHandler.GetMe)
 		auth.POST("/change-password", authMiddleware.AuthRequired(), authHandler.ChangePassword)
 	}
@@ -68,103 +107,243 @@ func SetupRouter(db *gorm.DB, config *config.Config) *gin.Engine {
 		api.Use(authMiddleware.AuthRequired())
 		{
 			users := api.Group("/users")
-			users.Use(authMiddleware.AdminRequired())
 			{
-				users.GET("", userHandler.GetUsers)
-				users.GET("/:id", userHandler.GetUser)
-				users.POST("", userHandler.CreateUser)
-				users.PUT("/:id", userHandler.UpdateUser)
-				users.DELETE("/:id", userHandler.DeleteUser)
-				users.GET("/:id/cards", userHandler.GetUserCards)
+				users.GET("", authMiddleware.RequirePrivilege(models.PrivilegeUsersRead), userHandler.GetUsers)
+				users.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeUsersRead), userHandler.GetUser)
+				users.POST("", authMiddleware.RequirePrivilege(models.PrivilegeUsersWrite), userHandler.CreateUser)
+				users.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegeUsersWrite), userHandler.UpdateUser)
+				users.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegeUsersWrite), userHandler.DeleteUser)
+				users.GET("/:id/cards", authMiddleware.RequirePrivilege(models.PrivilegeCardsRead), userHandler.GetUserCards)
+				users.PUT("/:id/pin", authMiddleware.RequirePrivilege(models.PrivilegeUsersWrite), userHandler.SetUserPIN)
+				users.DELETE("/:id/pin", authMiddleware.RequirePrivilege(models.PrivilegeUsersWrite), userHandler.DeleteUserPIN)
+				users.POST("/:id/pin/unlock", authMiddleware.RequirePrivilege(models.PrivilegeUsersWrite), userHandler.UnlockUserPIN)
+				users.POST("/:id/roles", authMiddleware.RequirePrivilege(models.PrivilegeRolesManage), roleHandler.AssignUserRole)
+				users.DELETE("/:id/roles/:role_id", authMiddleware.RequirePrivilege(models.PrivilegeRolesManage), roleHandler.RemoveUserRole)
+				users.POST("/:id/buildings", authMiddleware.RequirePrivilege(models.PrivilegeRolesManage), roleHandler.AssignUserBuilding)
+				users.DELETE("/:id/buildings/:building_id", authMiddleware.RequirePrivilege(models.PrivilegeRolesManage), roleHandler.RemoveUserBuilding)
 			}
 
 			cards := api.Group("/cards")
-			cards.Use(authMiddleware.AdminRequired())
 			{
-				cards.GET("", cardHandler.GetCards)
-				cards.GET("/:id", cardHandler.GetCard)
-				cards.POST("", cardHandler.CreateCard)
-				cards.PUT("/:id", cardHandler.UpdateCard)
-				cards.DELETE("/:id", cardHandler.DeleteCard)
-				cards.POST("/:id/block", cardHandler.BlockCard)
-				cards.POST("/:id/unblock", cardHandler.UnblockCard)
-				cards.POST("/:id/revoke", cardHandler.RevokeCard)
-				cards.GET("/expiring", cardHandler.GetExpiringCards)
+				cards.GET("", authMiddleware.RequirePrivilege(models.PrivilegeCardsRead), cardHandler.GetCards)
+				cards.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeCardsRead), cardHandler.GetCard)
+				cards.POST("", authMiddleware.RequirePrivilege(models.PrivilegeCardsWrite), cardHandler.CreateCard)
+				cards.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegeCardsWrite), cardHandler.UpdateCard)
+				cards.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegeCardsWrite), cardHandler.DeleteCard)
+				cards.POST("/:id/block", authMiddleware.RequirePrivilege(models.PrivilegeCardsBlock), cardHandler.BlockCard)
+				cards.POST("/:id/unblock", authMiddleware.RequirePrivilege(models.PrivilegeCardsBlock), cardHandler.UnblockCard)
+				cards.POST("/:id/revoke", authMiddleware.RequirePrivilege(models.PrivilegeCardsWrite), cardHandler.RevokeCard)
+				cards.GET("/expiring", authMiddleware.RequirePrivilege(models.PrivilegeCardsRead), cardHandler.GetExpiringCards)
 			}
 
 			rooms := api.Group("/rooms")
-			rooms.Use(authMiddleware.AdminRequired())
 			{
-				rooms.GET("", roomHandler.GetRooms)
-				rooms.GET("/:id", roomHandler.GetRoom)
-				rooms.POST("", roomHandler.CreateRoom)
-				rooms.PUT("/:id", roomHandler.UpdateRoom)
-				rooms.DELETE("/:id", roomHandler.DeleteRoom)
-				rooms.GET("/:id/permissions", roomHandler.GetRoomPermissions)
-				rooms.GET("/:id/logs", roomHandler.GetRoomLogs)
+				rooms.GET("", authMiddleware.RequirePrivilege(models.PrivilegeRoomsRead), roomHandler.GetRooms)
+				rooms.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeRoomsRead), roomHandler.GetRoom)
+				rooms.POST("", authMiddleware.RequirePrivilege(models.PrivilegeRoomsWrite), roomHandler.CreateRoom)
+				rooms.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegeRoomsWrite), roomHandler.UpdateRoom)
+				rooms.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegeRoomsWrite), roomHandler.DeleteRoom)
+				rooms.GET("/:id/permissions", authMiddleware.RequirePrivilege(models.PrivilegePermissionsRead), roomHandler.GetRoomPermissions)
+				rooms.GET("/:id/logs", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), roomHandler.GetRoomLogs)
+				rooms.GET("/:id/opening-hours", authMiddleware.RequirePrivilege(models.PrivilegeRoomsRead), roomHandler.GetRoomOpeningHours)
+				rooms.GET("/:id/occupancy", authMiddleware.RequirePrivilege(models.PrivilegeRoomsRead), roomHandler.GetRoomOccupancy)
+				rooms.DELETE("/:id/occupancy", authMiddleware.RequirePrivilege(models.PrivilegeRoomsWrite), roomHandler.ResetRoomOccupancy)
+				rooms.POST("/:id/unlock", authMiddleware.RequirePrivilege(models.PrivilegeDoorsControl), roomHandler.UnlockRoom)
+				rooms.POST("/:id/lock", authMiddleware.RequirePrivilege(models.PrivilegeDoorsControl), roomHandler.LockRoom)
+				rooms.GET("/:id/approvers", authMiddleware.RequirePrivilege(models.PrivilegeRoomsRead), roomHandler.GetRoomApprovers)
+				rooms.POST("/:id/approvers", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), roomHandler.AddRoomApprover)
+				rooms.DELETE("/:id/approvers/:user_id", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), roomHandler.RemoveRoomApprover)
+			}
+
+			buildings := api.Group("/buildings")
+			{
+				buildings.GET("", authMiddleware.RequirePrivilege(models.PrivilegeBuildingsRead), buildingHandler.GetBuildings)
+				buildings.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeBuildingsRead), buildingHandler.GetBuilding)
+				buildings.POST("", authMiddleware.RequirePrivilege(models.PrivilegeBuildingsWrite), buildingHandler.CreateBuilding)
+				buildings.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegeBuildingsWrite), buildingHandler.UpdateBuilding)
+				buildings.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegeBuildingsWrite), buildingHandler.DeleteBuilding)
+				buildings.GET("/:id/approvers", authMiddleware.RequirePrivilege(models.PrivilegeBuildingsRead), buildingHandler.GetBuildingApprovers)
+				buildings.POST("/:id/approvers", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), buildingHandler.AddBuildingApprover)
+				buildings.DELETE("/:id/approvers/:user_id", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), buildingHandler.RemoveBuildingApprover)
 			}
 
 			permissions := api.Group("/permissions")
-			permissions.Use(authMiddleware.AdminRequired())
 			{
-				permissions.GET("", permissionHandler.GetPermissions)
-				permissions.GET("/:id", permissionHandler.GetPermission)
-				permissions.POST("", permissionHandler.CreatePermission)
-				permissions.PUT("/:id", permissionHandler.UpdatePermission)
-				permissions.DELETE("/:id", permissionHandler.DeletePermission)
-				permissions.POST("/:id/revoke", permissionHandler.RevokePermission)
+				permissions.GET("", authMiddleware.RequirePrivilege(models.PrivilegePermissionsRead), permissionHandler.GetPermissions)
+				permissions.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegePermissionsRead), permissionHandler.GetPermission)
+				permissions.POST("", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), permissionHandler.CreatePermission)
+				permissions.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), permissionHandler.UpdatePermission)
+				permissions.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), permissionHandler.DeletePermission)
+				permissions.POST("/:id/revoke", authMiddleware.RequirePrivilege(models.PrivilegePermissionsWrite), permissionHandler.RevokePermission)
 			}
 
 			logs := api.Group("/logs")
-			logs.Use(authMiddleware.AdminRequired())
 			{
-				logs.GET("", logHandler.GetLogs)
-				logs.GET("/:id", logHandler.GetLog)
-				logs.POST("", logHandler.CreateLog)
+				logs.GET("", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), logHandler.GetLogs)
+				logs.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), logHandler.GetLog)
+				logs.POST("", authMiddleware.RequirePrivilege(models.PrivilegeLogsWrite), logHandler.CreateLog)
 
-				logs.GET("/stats/rooms", logHandler.GetRoomStats)
-				logs.GET("/stats/cards", logHandler.GetCardStats)
-				logs.GET("/stats/time-series", logHandler.GetAccessTimeSeries)
-				logs.GET("/stats/most-accessed-rooms", logHandler.GetMostAccessedRooms)
-				logs.GET("/stats/most-active-users", logHandler.GetMostActiveUsers)
+				logs.GET("/stats/rooms", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), logHandler.GetRoomStats)
+				logs.GET("/stats/cards", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), logHandler.GetCardStats)
+				logs.GET("/stats/time-series", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), logHandler.GetAccessTimeSeries)
+				logs.GET("/stats/most-accessed-rooms", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), logHandler.GetMostAccessedRooms)
+				logs.GET("/stats/most-active-users", authMiddleware.RequirePrivilege(models.PrivilegeLogsRead), logHandler.GetMostActiveUsers)
 			}
 
 			groups := api.Group("/groups")
-			groups.Use(authMiddleware.AdminRequired())
 			{
-				groups.GET("", groupHandler.GetGroups)
-				groups.GET("/:id", groupHandler.GetGroup)
-				groups.POST("", groupHandler.CreateGroup)
-				groups.PUT("/:id", groupHandler.UpdateGroup)
-				groups.DELETE("/:id", groupHandler.DeleteGroup)
+				groups.GET("", authMiddleware.RequirePrivilege(models.PrivilegeGroupsRead), groupHandler.GetGroups)
+				groups.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeGroupsRead), groupHandler.GetGroup)
+				groups.POST("", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.CreateGroup)
+				groups.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.UpdateGroup)
+				groups.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.DeleteGroup)
+
+				groups.GET("/:id/users", authMiddleware.RequirePrivilege(models.PrivilegeGroupsRead), groupHandler.GetGroupUsers)
+				groups.POST("/:id/users", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.AddUserToGroup)
+				groups.DELETE("/:id/users/:user_id", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.RemoveUserFromGroup)
+
+				groups.GET("/:id/rooms", authMiddleware.RequirePrivilege(models.PrivilegeGroupsRead), groupHandler.GetGroupRooms)
+				groups.GET("/:id/effective-rooms", authMiddleware.RequirePrivilege(models.PrivilegeGroupsRead), groupHandler.GetGroupEffectiveRooms)
+				groups.POST("/:id/rooms", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.AddRoomToGroup)
+				groups.PUT("/:id/rooms/:room_id", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.UpdateGroupRoom)
+				groups.POST("/:id/rooms/:room_id/revoke", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.RevokeGroupRoom)
+				groups.DELETE("/:id/rooms/:room_id", authMiddleware.RequirePrivilege(models.PrivilegeGroupsWrite), groupHandler.RemoveRoomFromGroup)
+			}
+
+			devices := api.Group("/devices")
+			{
+				devices.GET("", authMiddleware.RequirePrivilege(models.PrivilegeDevicesRead), deviceHandler.GetDevices)
+				devices.GET("/health", authMiddleware.RequirePrivilege(models.PrivilegeDevicesRead), deviceHandler.GetDeviceHealth)
+				devices.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeDevicesRead), deviceHandler.GetDevice)
+				devices.POST("", authMiddleware.RequirePrivilege(models.PrivilegeDevicesWrite), deviceHandler.CreateDevice)
+				devices.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegeDevicesWrite), deviceHandler.UpdateDevice)
+				devices.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegeDevicesWrite), deviceHandler.DeleteDevice)
+				devices.POST("/:id/enable", authMiddleware.RequirePrivilege(models.PrivilegeDevicesWrite), deviceHandler.EnableDevice)
+				devices.POST("/:id/disable", authMiddleware.RequirePrivilege(models.PrivilegeDevicesWrite), deviceHandler.DisableDevice)
+				devices.POST("/:id/commands", authMiddleware.RequirePrivilege(models.PrivilegeDevicesWrite), deviceHandler.SendCommand)
+			}
+
+			apiKeys := api.Group("/api-keys")
+			apiKeys.Use(authMiddleware.RequirePrivilege(models.PrivilegeAPIKeysManage))
+			{
+				apiKeys.GET("", apiKeyHandler.GetAPIKeys)
+				apiKeys.GET("/:id", apiKeyHandler.GetAPIKey)
+				apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+				apiKeys.POST("/:id/revoke", apiKeyHandler.RevokeAPIKey)
+				apiKeys.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+			}
+
+			emergencies := api.Group("/emergencies")
+			{
+				emergencies.GET("", authMiddleware.RequirePrivilege(models.PrivilegeEmergenciesRead), emergencyHandler.GetEmergencies)
+				emergencies.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeEmergenciesRead), emergencyHandler.GetEmergency)
+				emergencies.POST("", authMiddleware.RequirePrivilege(models.PrivilegeEmergenciesManage), emergencyHandler.ActivateEmergency)
+				emergencies.POST("/:id/clear", authMiddleware.RequirePrivilege(models.PrivilegeEmergenciesManage), emergencyHandler.ClearEmergency)
+			}
 
-				groups.GET("/:id/users", groupHandler.GetGroupUsers)
-				groups.POST("/:id/users", groupHandler.AddUserToGroup)
-				groups.DELETE("/:id/users/:user_id", groupHandler.RemoveUserFromGroup)
+			calendar := api.Group("/calendar-exceptions")
+			{
+				calendar.GET("", authMiddleware.RequirePrivilege(models.PrivilegeCalendarRead), calendarHandler.GetCalendarExceptions)
+				calendar.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeCalendarRead), calendarHandler.GetCalendarException)
+				calendar.POST("", authMiddleware.RequirePrivilege(models.PrivilegeCalendarWrite), calendarHandler.CreateCalendarException)
+				calendar.POST("/import", authMiddleware.RequirePrivilege(models.PrivilegeCalendarWrite), calendarHandler.ImportCalendar)
+				calendar.PUT("/:id", authMiddleware.RequirePrivilege(models.PrivilegeCalendarWrite), calendarHandler.UpdateCalendarException)
+				calendar.DELETE("/:id", authMiddleware.RequirePrivilege(models.PrivilegeCalendarWrite), calendarHandler.DeleteCalendarException)
+			}
 
-				groups.GET("/:id/rooms", groupHandler.GetGroupRooms)
-				groups.POST("/:id/rooms", groupHandler.AddRoomToGroup)
-				groups.DELETE("/:id/rooms/:room_id", groupHandler.RemoveRoomFromGroup)
+			visits := api.Group("/visits")
+			visits.Use(authMiddleware.RequirePrivilege(models.PrivilegeVisitsHost))
+			{
+				visits.GET("", visitHandler.GetVisits)
+				visits.GET("/:id", visitHandler.GetVisit)
+				visits.POST("", visitHandler.CreateVisit)
+				visits.POST("/:id/end", visitHandler.EndVisit)
 			}
 
+			visitorCards := api.Group("/visitor-cards")
+			visitorCards.Use(authMiddleware.RequirePrivilege(models.PrivilegeVisitorsManage))
+			{
+				visitorCards.GET("", visitHandler.GetPoolCards)
+				visitorCards.POST("", visitHandler.AddPoolCard)
+			}
+
+			roles := api.Group("/roles")
+			roles.Use(authMiddleware.RequirePrivilege(models.PrivilegeRolesManage))
+			{
+				roles.GET("", roleHandler.GetRoles)
+				roles.GET("/:id", roleHandler.GetRole)
+				roles.POST("", roleHandler.CreateRole)
+				roles.PUT("/:id", roleHandler.UpdateRole)
+				roles.DELETE("/:id", roleHandler.DeleteRole)
+			}
+
+			recertifications := api.Group("/recertifications")
+			{
+				recertifications.GET("", authMiddleware.RequirePrivilege(models.PrivilegeRecertificationsRead), recertificationHandler.GetCampaigns)
+				recertifications.GET("/:id", authMiddleware.RequirePrivilege(models.PrivilegeRecertificationsRead), recertificationHandler.GetCampaign)
+				recertifications.GET("/:id/report", authMiddleware.RequirePrivilege(models.PrivilegeRecertificationsRead), recertificationHandler.GetCampaignReport)
+				recertifications.POST("", authMiddleware.RequirePrivilege(models.PrivilegeRecertificationsManage), recertificationHandler.CreateCampaign)
+				recertifications.POST("/:id/complete", authMiddleware.RequirePrivilege(models.PrivilegeRecertificationsManage), recertificationHandler.CompleteCampaign)
+			}
+
+			api.GET("/privileges", authMiddleware.RequirePrivilege(models.PrivilegeRolesManage), roleHandler.GetPrivileges)
+
 			api.POST("/check-access", cardHandler.CheckAccess)
 
+			access := api.Group("/access")
+			access.Use(authMiddleware.RequirePrivilege(models.PrivilegeAccessExplain))
+			{
+				access.POST("/explain", accessHandler.ExplainAccess)
+			}
+
 			simulation := api.Group("/simulate")
-			simulation.Use(authMiddleware.AdminRequired())
+			simulation.Use(authMiddleware.RequirePrivilege(models.PrivilegeAccessSimulate))
 			{
 				simulation.POST("/access", simulationHandler.SimulateAccess)
 			}
+
+			// Self service needs no privilege, every query is bound to the
+			// caller.
+			me := api.Group("/me")
+			{
+				me.GET("/cards", selfServiceHandler.GetMyCards)
+				me.POST("/cards/:id/lost", selfServiceHandler.ReportCardLost)
+				me.GET("/access", selfServiceHandler.GetMyAccess)
+				me.GET("/logs", selfServiceHandler.GetMyLogs)
+				me.GET("/access-requests", accessRequestHandler.GetMyAccessRequests)
+				me.POST("/access-requests", accessRequestHandler.CreateAccessRequest)
+				me.POST("/access-requests/:id/cancel", accessRequestHandler.CancelAccessRequest)
+			}
+
+			// Approvers need not hold any privilege, the service checks that
+			// the caller may decide the request.
+			accessRequests := api.Group("/access-requests")
+			{
+				accessRequests.GET("", accessRequestHandler.GetAccessRequests)
+				accessRequests.GET("/:id", accessRequestHandler.GetAccessRequest)
+				accessRequests.POST("/:id/approve", accessRequestHandler.ApproveAccessRequest)
+				accessRequests.POST("/:id/reject", accessRequestHandler.RejectAccessRequest)
+			}
+
+			// Reviewers are the approvers of the rooms, whatever their role.
+			recertificationReviews := api.Group("/recertification-reviews")
+			{
+				recertificationReviews.GET("", recertificationHandler.GetMyReviews)
+				recertificationReviews.POST("/:id/confirm", recertificationHandler.ConfirmReview)
+				recertificationReviews.POST("/:id/revoke", recertificationHandler.RevokeReview)
+			}
 		}
 	}
 
 	cardReader := router.Group("/reader")
-
-	if config.APIKeyRequired {
-		cardReader.Use(apiKeyMiddleware.APIKeyRequired())
-	}
+	cardReader.Use(apiKeyMiddleware.ReaderKeyRequired())
 
 	{
-		cardReader.POST("/check-access", cardHandler.CheckAccess)
+		cardReader.POST("/check-access", cardHandler.ReaderCheckAccess)
+		cardReader.POST("/heartbeat", deviceHandler.Heartbeat)
+		cardReader.GET("/access-list", deviceHandler.GetAccessList)
+		cardReader.GET("/access-list/public-key", deviceHandler.GetAccessListPublicKey)
+		cardReader.POST("/offline-swipes", deviceHandler.UploadOfflineSwipes)
 	}
 
 	return router
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -9,8 +10,22 @@ import (
 	"rfid/internal/websocket"
 )
 
+// A reader that saw unknownCredentialLimit unknown credentials within
+// unknownCredentialWindow takes no keypad entries until the window passes.
+const (
+	unknownCredentialLimit  = 10
+	unknownCredentialWindow = time.Minute
+)
+
 type AccessControlService struct {
 	db         *gorm.DB
+	groups     *GroupHierarchyService
+	occupancy  *OccupancyService
+	emergency  *EmergencyService
+	calendar   *CalendarService
+	timezones  *TimezoneService
+	pins       *PINService
+	visits     *VisitService
 	wsHandler  *websocket.WebSocketHandler
 	wsEnabled  bool
 }
@@ -18,6 +33,13 @@ type AccessControlService struct {
 func NewAccessControlService(db *gorm.DB) *AccessControlService {
 	return &AccessControlService{
 		db:         db,
+		groups:     NewGroupHierarchyService(db),
+		occupancy:  NewOccupancyService(db),
+		emergency:  NewEmergencyService(db),
+		calendar:   NewCalendarService(db),
+		timezones:  NewTimezoneService(db),
+		pins:       NewPINService(db),
+		visits:     NewVisitService(db),
 		wsEnabled:  false,
 	}
 }
@@ -25,134 +47,221 @@ func NewAccessControlService(db *gorm.DB) *AccessControlService {
 func (acs *AccessControlService) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
 	acs.wsHandler = wsHandler
 	acs.wsEnabled = (wsHandler != nil)
+	acs.visits.SetWebSocketHandler(wsHandler)
 }
 
 func (acs *AccessControlService) CheckAccess(cardID string, roomID uint, deviceID string) (bool, models.DenialReason, error) {
-	var card models.Card
-	if err := acs.db.Preload("Permissions").Preload("User").First(&card, "card_id = ?", cardID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return false, models.DenialReasonNoPermission, nil
-		}
-		return false, "", err
-	}
+	return acs.Authorize(AccessRequest{
+		CardID:   cardID,
+		RoomID:   roomID,
+		DeviceID: deviceID,
+	})
+}
 
-	cardWasActive := card.Status == models.CardStatusActive
+func (acs *AccessControlService) Authorize(req AccessRequest) (bool, models.DenialReason, error) {
+	if req.Time.IsZero() {
+		req.Time = time.Now()
+	}
 
-	if !card.IsActive() {
-		if cardWasActive && card.Status == models.CardStatusExpired {
-			acs.db.Save(&card)
+	if req.CredentialType == models.CredentialPIN {
+		throttled, err := acs.keypadThrottled(req)
+		if err != nil {
+			return false, models.DenialReasonPermissionError, err
 		}
-
-		var denialReason models.DenialReason
-		if card.Status == models.CardStatusBlocked {
-			denialReason = models.DenialReasonCardBlocked
-		} else if card.Status == models.CardStatusRevoked {
-			denialReason = models.DenialReasonCardRevoked
-		} else if card.Status == models.CardStatusExpired {
-			denialReason = models.DenialReasonCardExpired
-		} else {
-			denialReason = models.DenialReasonCardInactive
+		if throttled {
+			acs.recordUnknownCredential(req, models.DenialReasonTooManyAttempts)
+			return false, models.DenialReasonTooManyAttempts, nil
 		}
-
-		acs.LogAccess(card.ID, roomID, models.AccessDenied, denialReason, deviceID)
-		return false, denialReason, nil
 	}
 
-	var room models.Room
-	if err := acs.db.First(&room, roomID).Error; err != nil {
+	ev, err := acs.evaluate(req)
+	if err != nil {
 		return false, models.DenialReasonPermissionError, err
 	}
 
-	currentTime := time.Now()
-
-	if !room.IsAccessibleAtTime(currentTime) {
-		acs.LogAccess(card.ID, roomID, models.AccessDenied, models.DenialReasonOutsideHours, deviceID)
-		return false, models.DenialReasonOutsideHours, nil
+	if ev.card == nil {
+		acs.recordUnknownCredential(req, models.DenialReasonUnknownCard)
+		return false, ev.reason, nil
 	}
 
-	if room.IsAccessibleWithoutCard() {
-		card.LastUsed = &currentTime
-		acs.db.Save(&card)
+	card := ev.card
+	if ev.cardWasActive && card.Status == models.CardStatusExpired {
+		acs.db.Save(card)
+	}
 
-		acs.LogAccess(card.ID, roomID, models.AccessGranted, "", deviceID)
+	entry := models.Log{
+		CardID:      card.ID,
+		RoomID:      req.RoomID,
+		Timestamp:   req.Time,
+		Direction:   req.Direction,
+		DeviceID:    req.DeviceID,
+		Description: ev.warning,
+	}
 
-		return true, "", nil
+	if ev.pinFailed {
+		locked, err := acs.pins.RecordFailure(card.UserID, req.Time)
+		if err != nil {
+			return false, models.DenialReasonPermissionError, err
+		}
+		if locked {
+			entry.Description = "PIN kód zárolva túl sok hibás próbálkozás miatt"
+		}
+	} else if ev.pinReset {
+		acs.pins.Unlock(card.UserID)
 	}
 
-	if card.CanAccessRoom(roomID, currentTime) {
-		card.LastUsed = &currentTime
-		acs.db.Save(&card)
+	if !ev.granted {
+		entry.AccessResult = models.AccessDenied
+		entry.DenialReason = ev.reason
+		acs.recordLog(entry)
+		return false, ev.reason, nil
+	}
 
-		acs.LogAccess(card.ID, roomID, models.AccessGranted, "", deviceID)
+	card.LastUsed = &req.Time
+	acs.db.Save(card)
 
-		return true, "", nil
+	if ev.dualPartner != nil {
+		entry.LinkedLogID = &ev.dualPartner.ID
 	}
 
-	if card.UserID != 0 {
-		var directPermissions []models.Permission
-		if err := acs.db.Where("user_id = ? AND room_id = ? AND active = ?", card.UserID, roomID, true).Find(&directPermissions).Error; err != nil {
-			return false, models.DenialReasonPermissionError, err
-		}
+	entry.AccessResult = models.AccessGranted
+	entry = acs.recordLog(entry)
 
-		for _, perm := range directPermissions {
-			if perm.IsValid(currentTime) {
-				card.LastUsed = &currentTime
-				acs.db.Save(&card)
+	acs.updateOccupancy(*ev.room, *card, req)
 
-				acs.LogAccess(card.ID, roomID, models.AccessGranted, "", deviceID)
+	if ev.room.IsMainEntrance && card.User.IsVisitor && req.Direction != models.DirectionExit {
+		acs.visits.NotifyArrival(*card, *ev.room, req.Time)
+	}
 
-				return true, "", nil
-			}
-		}
+	if ev.dualPartner != nil {
+		acs.completeDualAuthorization(*ev.room, *ev.dualPartner, entry)
 	}
 
-	if card.UserID != 0 {
-		var groupsCount int64
-		err := acs.db.Raw(`
-			SELECT COUNT(g.id)
-			FROM groups g
-			JOIN user_groups ug ON g.id = ug.group_id
-			JOIN group_rooms gr ON g.id = gr.group_id
-			WHERE ug.user_id = ? AND gr.room_id = ? AND g.deleted_at IS NULL
-		`, card.UserID, roomID).Count(&groupsCount).Error
+	return true, "", nil
+}
 
-		if err != nil {
-			return false, models.DenialReasonPermissionError, err
-		}
+// completeDualAuthorization grants the pending first tap once the second
+// one arrived, and links it back to the second tap.
+func (acs *AccessControlService) completeDualAuthorization(room models.Room, pending models.Log, second models.Log) {
+	err := acs.db.Model(&models.Log{}).Where("id = ?", pending.ID).Updates(map[string]interface{}{
+		"access_result": models.AccessGranted,
+		"denial_reason": "",
+		"linked_log_id": second.ID,
+	}).Error
+	if err != nil {
+		return
+	}
 
-		if groupsCount > 0 {
-			card.LastUsed = &currentTime
-			acs.db.Save(&card)
+	pending.AccessResult = models.AccessGranted
+	pending.DenialReason = ""
+	pending.LinkedLogID = &second.ID
 
-			acs.LogAccess(card.ID, roomID, models.AccessGranted, "", deviceID)
+	var card models.Card
+	if err := acs.db.First(&card, pending.CardID).Error; err != nil {
+		return
+	}
+	acs.db.Model(&card).Update("last_used", pending.Timestamp)
 
-			return true, "", nil
-		}
+	if acs.wsEnabled {
+		acs.wsHandler.NotifyAccessEvent(pending)
 	}
 
-	acs.LogAccess(card.ID, roomID, models.AccessDenied, models.DenialReasonNoPermission, deviceID)
+	acs.updateOccupancy(room, card, AccessRequest{
+		RoomID:    room.ID,
+		DeviceID:  pending.DeviceID,
+		Direction: pending.Direction,
+		Time:      pending.Timestamp,
+	})
+}
+
+func (acs *AccessControlService) updateOccupancy(room models.Room, card models.Card, req AccessRequest) {
+	var changed bool
+	var err error
+
+	switch req.Direction {
+	case models.DirectionEntry:
+		changed, err = acs.occupancy.RecordEntry(room.ID, card, req.Time)
+	case models.DirectionExit:
+		changed, err = acs.occupancy.RecordExit(room.ID, card.ID)
+	default:
+		return
+	}
+
+	if err != nil || !changed || !acs.wsEnabled {
+		return
+	}
 
-	return false, models.DenialReasonNoPermission, nil
+	count, err := acs.occupancy.Count(room.ID)
+	if err != nil {
+		return
+	}
+
+	acs.wsHandler.NotifyOccupancyChange(room, count, card, req.Direction)
 }
 
 func (acs *AccessControlService) LogAccess(cardID uint, roomID uint, result models.AccessResult, denialReason models.DenialReason, deviceID string) {
-	log := models.Log{
+	acs.recordLog(models.Log{
 		CardID:       cardID,
 		RoomID:       roomID,
 		Timestamp:    time.Now(),
 		AccessResult: result,
 		DenialReason: denialReason,
 		DeviceID:     deviceID,
+	})
+}
+
+// keypadThrottled refuses keypad entries on a reader that saw too many
+// unknown credentials lately, so keypad codes cannot be guessed in bulk.
+func (acs *AccessControlService) keypadThrottled(req AccessRequest) (bool, error) {
+	var attempts int64
+	err := acs.db.Model(&models.Log{}).
+		Where("card_id = 0 AND device_id = ? AND denial_reason IN ? AND timestamp >= ?",
+			req.DeviceID,
+			[]models.DenialReason{models.DenialReasonUnknownCard, models.DenialReasonTooManyAttempts},
+			req.Time.Add(-unknownCredentialWindow).Local()).
+		Count(&attempts).Error
+	if err != nil {
+		return false, err
+	}
+
+	return attempts >= unknownCredentialLimit, nil
+}
+
+// recordUnknownCredential logs an attempt that matched no credential. The
+// entry has no card, so it is not broadcast as an access event.
+func (acs *AccessControlService) recordUnknownCredential(req AccessRequest, reason models.DenialReason) {
+	credentialType := req.CredentialType
+	if credentialType == "" {
+		credentialType = models.CredentialCard
 	}
 
-	acs.db.Create(&log)
+	acs.db.Create(&models.Log{
+		RoomID:       req.RoomID,
+		Timestamp:    req.Time,
+		AccessResult: models.AccessDenied,
+		DenialReason: reason,
+		Direction:    req.Direction,
+		DeviceID:     req.DeviceID,
+		Description:  fmt.Sprintf("Ismeretlen azonosító (%s): %s", credentialType, req.CardID),
+	})
+}
+
+func (acs *AccessControlService) recordLog(entry models.Log) models.Log {
+	acs.db.Create(&entry)
 
 	if acs.wsEnabled {
-		acs.wsHandler.NotifyAccessEvent(cardID, roomID, string(result), string(denialReason))
+		acs.wsHandler.NotifyAccessEvent(entry)
 	}
+
+	return entry
 }
 
 func (acs *AccessControlService) GrantAccess(cardID uint, roomID uint, grantedBy uint, validUntil *time.Time, timeRestriction string) error {
+	schedule, err := models.ParseSchedule(timeRestriction)
+	if err != nil {
+		return err
+	}
+
 	cardIDPtr := &cardID
 	permission := models.Permission{
 		CardID:          cardIDPtr,
@@ -160,7 +269,7 @@ func (acs *AccessControlService) GrantAccess(cardID uint, roomID uint, grantedBy
 		GrantedBy:       grantedBy,
 		ValidFrom:       time.Now(),
 		ValidUntil:      validUntil,
-		TimeRestriction: timeRestriction,
+		TimeRestriction: schedule.String(),
 		Active:          true,
 	}
 
@@ -168,6 +277,11 @@ func (acs *AccessControlService) GrantAccess(cardID uint, roomID uint, grantedBy
 }
 
 func (acs *AccessControlService) GrantDirectAccess(userID uint, roomID uint, grantedBy uint, validUntil *time.Time, timeRestriction string) error {
+	schedule, err := models.ParseSchedule(timeRestriction)
+	if err != nil {
+		return err
+	}
+
 	userIDPtr := &userID
 	permission := models.Permission{
 		UserID:          userIDPtr,
@@ -175,7 +289,7 @@ func (acs *AccessControlService) GrantDirectAccess(userID uint, roomID uint, gra
 		GrantedBy:       grantedBy,
 		ValidFrom:       time.Now(),
 		ValidUntil:      validUntil,
-		TimeRestriction: timeRestriction,
+		TimeRestriction: schedule.String(),
 		Active:          true,
 	}
 
@@ -197,14 +311,20 @@ func (acs *AccessControlService) RevokeDirectAccess(userID uint, roomID uint) er
 }
 
 func (acs *AccessControlService) RegisterCard(userID uint, cardID string) (models.Card, error) {
-	encryptedCardID, err := EncryptCardID(cardID)
+	return acs.RegisterCredential(userID, models.CredentialCard, cardID, "")
+}
+
+func (acs *AccessControlService) RegisterCredential(userID uint, credentialType models.CredentialType, value string, label string) (models.Card, error) {
+	encryptedCardID, err := EncryptCardID(value)
 	if err != nil {
 		return models.Card{}, err
 	}
 
 	card := models.Card{
 		UserID:          userID,
-		CardID:          cardID,
+		CredentialType:  credentialType,
+		Label:           label,
+		CardID:          value,
 		EncryptedCardID: encryptedCardID,
 		Status:          models.CardStatusActive,
 		IssueDate:       time.Now(),
@@ -217,6 +337,10 @@ func (acs *AccessControlService) RegisterCard(userID uint, cardID string) (model
 	return card, nil
 }
 
+func (acs *AccessControlService) FindCredential(credentialType models.CredentialType, value string) (*models.Card, error) {
+	return findCredential(acs.db, credentialType, value)
+}
+
 func (acs *AccessControlService) BlockCard(cardID uint) error {
 	return acs.db.Model(&models.Card{}).
 		Where("id = ?", cardID).
@@ -224,10 +348,24 @@ func (acs *AccessControlService) BlockCard(cardID uint) error {
 		Error
 }
 
+// ReportLost blocks the card on behalf of its holder.
+func (acs *AccessControlService) ReportLost(cardID uint, at time.Time) error {
+	return acs.db.Model(&models.Card{}).
+		Where("id = ?", cardID).
+		Updates(map[string]interface{}{
+			"status":           models.CardStatusBlocked,
+			"lost_reported_at": at,
+		}).
+		Error
+}
+
 func (acs *AccessControlService) UnblockCard(cardID uint) error {
 	return acs.db.Model(&models.Card{}).
 		Where("id = ? AND status = ?", cardID, models.CardStatusBlocked).
-		Update("status", models.CardStatusActive).
+		Updates(map[string]interface{}{
+			"status":           models.CardStatusActive,
+			"lost_reported_at": nil,
+		}).
 		Error
 }
 
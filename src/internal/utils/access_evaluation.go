@@ -0,0 +1,511 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+type AccessRequest struct {
+	CardID         string
+	CredentialType models.CredentialType
+	RoomID         uint
+	DeviceID       string
+	Direction      models.AccessDirection
+	Time           time.Time
+
+	// PIN is the second factor entered on the reader, only looked at in
+	// rooms with RequiresPIN.
+	PIN string
+}
+
+type TraceStep struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type PermissionVerdict struct {
+	PermissionID    uint       `json:"permission_id"`
+	Source          string     `json:"source"`
+	ValidFrom       time.Time  `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+	TimeRestriction string     `json:"time_restriction,omitempty"`
+	Valid           bool       `json:"valid"`
+	Reason          string     `json:"reason,omitempty"`
+}
+
+type GroupPathVerdict struct {
+	Path         []string   `json:"path"`
+	GroupIDs     []uint     `json:"group_ids"`
+	GrantGroupID *uint      `json:"grant_group_id,omitempty"`
+	ValidFrom    *time.Time `json:"valid_from,omitempty"`
+	ValidUntil   *time.Time `json:"valid_until,omitempty"`
+	Valid        bool       `json:"valid"`
+	Reason       string     `json:"reason,omitempty"`
+}
+
+type AccessTrace struct {
+	CardID       string                 `json:"card_id"`
+	RoomID       uint                   `json:"room_id"`
+	Direction    models.AccessDirection `json:"direction,omitempty"`
+	EvaluatedAt  time.Time              `json:"evaluated_at"`
+	Granted      bool                   `json:"granted"`
+	DenialReason models.DenialReason    `json:"denial_reason,omitempty"`
+	Steps        []TraceStep            `json:"steps"`
+	Permissions  []PermissionVerdict    `json:"permissions"`
+	GroupPaths   []GroupPathVerdict     `json:"group_paths"`
+}
+
+type accessEvaluation struct {
+	card           *models.Card
+	room           *models.Room
+	cardWasActive  bool
+	granted        bool
+	reason         models.DenialReason
+	timeRestricted bool
+	warning        string
+	pinReset       bool
+	pinFailed      bool
+	dualPartner    *models.Log
+	trace          AccessTrace
+}
+
+func (ev *accessEvaluation) step(check string, passed bool, detail string) {
+	ev.trace.Steps = append(ev.trace.Steps, TraceStep{Check: check, Passed: passed, Detail: detail})
+}
+
+// deny keeps the first denial reason, later checks only add to the trace.
+func (ev *accessEvaluation) deny(reason models.DenialReason) {
+	if ev.reason == "" {
+		ev.reason = reason
+	}
+}
+
+func (ev *accessEvaluation) finish() {
+	if ev.reason == "" && !ev.granted {
+		if ev.timeRestricted {
+			ev.reason = models.DenialReasonTimeRestricted
+		} else {
+			ev.reason = models.DenialReasonNoPermission
+		}
+	}
+	if ev.reason != "" {
+		ev.granted = false
+	}
+
+	ev.trace.Granted = ev.granted
+	ev.trace.DenialReason = ev.reason
+	if ev.trace.Permissions == nil {
+		ev.trace.Permissions = []PermissionVerdict{}
+	}
+	if ev.trace.GroupPaths == nil {
+		ev.trace.GroupPaths = []GroupPathVerdict{}
+	}
+}
+
+func cardStatusDenialReason(status models.CardStatus) models.DenialReason {
+	switch status {
+	case models.CardStatusBlocked:
+		return models.DenialReasonCardBlocked
+	case models.CardStatusRevoked:
+		return models.DenialReasonCardRevoked
+	case models.CardStatusExpired:
+		return models.DenialReasonCardExpired
+	default:
+		return models.DenialReasonCardInactive
+	}
+}
+
+// evaluate decides an access request without any side effects, recording
+// every check it makes in the trace.
+func (acs *AccessControlService) evaluate(req AccessRequest) (*accessEvaluation, error) {
+	if req.Time.IsZero() {
+		req.Time = time.Now()
+	}
+
+	ev := &accessEvaluation{
+		trace: AccessTrace{
+			CardID:      req.CardID,
+			RoomID:      req.RoomID,
+			Direction:   req.Direction,
+			EvaluatedAt: req.Time,
+		},
+	}
+
+	found, err := findCredential(acs.db, req.CredentialType, req.CardID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			ev.step("card_lookup", false, "A kártya nincs regisztrálva")
+			ev.deny(models.DenialReasonNoPermission)
+			ev.finish()
+			return ev, nil
+		}
+		return nil, err
+	}
+	card := *found
+	ev.card = &card
+	ev.step("card_lookup", true, fmt.Sprintf("Kártya #%d (%s), felhasználó #%d", card.ID, card.CredentialType, card.UserID))
+
+	ev.cardWasActive = card.Status == models.CardStatusActive
+	if card.IsActive() {
+		ev.step("card_status", true, string(card.Status))
+	} else {
+		ev.step("card_status", false, string(card.Status))
+		ev.deny(cardStatusDenialReason(card.Status))
+	}
+
+	var room models.Room
+	if err := acs.db.First(&room, req.RoomID).Error; err != nil {
+		return nil, err
+	}
+	ev.room = &room
+
+	// Everything after the room lookup runs on the wall clock of the room.
+	req.Time = req.Time.In(acs.timezones.RoomLocation(room))
+	ev.trace.EvaluatedAt = req.Time
+
+	decided, err := acs.evaluateEmergency(ev)
+	if err != nil {
+		return nil, err
+	}
+	if decided {
+		ev.finish()
+		return ev, nil
+	}
+
+	exception, err := acs.calendar.ExceptionAt(room, req.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case exception != nil && exception.Closes():
+		ev.step("calendar", false, calendarDetail(exception))
+		ev.deny(models.DenialReasonRoomClosed)
+	case exception != nil:
+		ev.step("calendar", true, calendarDetail(exception))
+	case room.IsAccessibleAtTime(req.Time):
+		ev.step("room_hours", true, "Nyitvatartás: "+room.OpeningHours)
+	default:
+		ev.step("room_hours", false, "Nyitvatartás: "+room.OpeningHours)
+		ev.deny(models.DenialReasonOutsideHours)
+	}
+
+	if room.IsAccessibleWithoutCard() {
+		ev.step("public_room", true, "Nyilvános helyiség, nem szükséges jogosultság")
+		ev.granted = true
+	} else {
+		ev.step("public_room", false, string(room.AccessLevel))
+	}
+
+	for _, perm := range card.Permissions {
+		if perm.RoomID != req.RoomID {
+			continue
+		}
+		ev.addPermission(perm, "card", req.Time)
+	}
+
+	if card.UserID != 0 {
+		var directPermissions []models.Permission
+		if err := acs.db.Where("user_id = ? AND room_id = ?", card.UserID, req.RoomID).Find(&directPermissions).Error; err != nil {
+			return nil, err
+		}
+
+		for _, perm := range directPermissions {
+			ev.addPermission(perm, "user", req.Time)
+		}
+
+		if err := acs.evaluateGroups(ev, card.UserID, req.RoomID, req.Time); err != nil {
+			return nil, err
+		}
+	}
+
+	if ev.granted && ev.reason == "" {
+		if err := acs.evaluateAntiPassback(ev, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if ev.granted && ev.reason == "" {
+		if err := acs.evaluateCapacity(ev, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if ev.granted && ev.reason == "" {
+		if err := acs.evaluatePIN(ev, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if ev.granted && ev.reason == "" {
+		if err := acs.evaluateDualAuthorization(ev, req); err != nil {
+			return nil, err
+		}
+	}
+
+	ev.finish()
+	return ev, nil
+}
+
+func calendarDetail(exception *models.CalendarException) string {
+	detail := fmt.Sprintf("Naptári kivétel #%d (%s, %s): %s", exception.ID, exception.Type, exception.Scope, exception.Reason)
+	if exception.Hours != "" {
+		detail += ", " + exception.Hours
+	}
+	return detail
+}
+
+// evaluateEmergency overrides the normal checks while an emergency covers
+// the room: an evacuation opens it for every card, a lockdown closes it for
+// everyone outside the responder group.
+func (acs *AccessControlService) evaluateEmergency(ev *accessEvaluation) (bool, error) {
+	state, err := acs.emergency.ForRoom(*ev.room)
+	if err != nil || state == nil {
+		return false, err
+	}
+
+	detail := fmt.Sprintf("Vészhelyzet #%d (%s): %s", state.ID, state.Mode, state.Reason)
+
+	if state.Mode == models.EmergencyEvacuation {
+		ev.step("emergency", true, "Kiürítés, minden ajtó nyitva. "+detail)
+		ev.granted = true
+		return true, nil
+	}
+
+	if state.ResponderGroupID != nil && ev.card.UserID != 0 {
+		groupIDs, err := acs.groups.UserGroupIDs(ev.card.UserID)
+		if err != nil {
+			return false, err
+		}
+		for _, groupID := range groupIDs {
+			if groupID == *state.ResponderGroupID {
+				ev.step("emergency", true, "Zárlat, beavatkozó csoport tagja. "+detail)
+				ev.granted = true
+				return true, nil
+			}
+		}
+	}
+
+	ev.step("emergency", false, "Zárlat. "+detail)
+	ev.deny(models.DenialReasonRoomClosed)
+	return true, nil
+}
+
+func (acs *AccessControlService) evaluateAntiPassback(ev *accessEvaluation, req AccessRequest) error {
+	mode := ev.room.AntiPassback
+	if mode == "" || mode == models.AntiPassbackOff || req.Direction != models.DirectionEntry {
+		return nil
+	}
+
+	// Pooled visitor cards are reissued with a new issue date, the swipes of
+	// the previous holder do not count.
+	var last models.Log
+	err := acs.db.
+		Where("card_id = ? AND room_id = ? AND access_result = ? AND direction IN ? AND timestamp >= ?",
+			ev.card.ID, ev.room.ID, models.AccessGranted,
+			[]models.AccessDirection{models.DirectionEntry, models.DirectionExit}, ev.card.IssueDate).
+		Order("timestamp DESC").
+		Order("id DESC").
+		First(&last).Error
+
+	if err == gorm.ErrRecordNotFound || (err == nil && last.Direction != models.DirectionEntry) {
+		ev.step("anti_passback", true, "Nincs kilépés nélküli korábbi belépés")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	detail := "Ismételt belépés kilépés nélkül, utolsó belépés: " + last.Timestamp.Format(time.RFC3339)
+
+	if mode == models.AntiPassbackHard {
+		ev.step("anti_passback", false, detail)
+		ev.deny(models.DenialReasonAntiPassback)
+		return nil
+	}
+
+	ev.step("anti_passback", true, "Figyelmeztetés: "+detail)
+	ev.warning = "Anti-passback figyelmeztetés: " + detail
+	return nil
+}
+
+// evaluateCapacity only limits entries, a card that is already inside is not
+// counted against itself.
+func (acs *AccessControlService) evaluateCapacity(ev *accessEvaluation, req AccessRequest) error {
+	if ev.room.Capacity <= 0 || req.Direction != models.DirectionEntry {
+		return nil
+	}
+
+	inside, err := acs.occupancy.IsInside(ev.room.ID, ev.card.ID)
+	if err != nil {
+		return err
+	}
+
+	count, err := acs.occupancy.Count(ev.room.ID)
+	if err != nil {
+		return err
+	}
+
+	detail := fmt.Sprintf("Létszám: %d / %d", count, ev.room.Capacity)
+	if inside || count < ev.room.Capacity {
+		ev.step("capacity", true, detail)
+		return nil
+	}
+
+	ev.step("capacity", false, detail)
+	ev.deny(models.DenialReasonCapacityReached)
+	return nil
+}
+
+// evaluatePIN runs last, so a wrong PIN is only counted against the user
+// when the card alone would have opened the door. A PIN credential always
+// needs the PIN, its keypad code alone opens nothing.
+func (acs *AccessControlService) evaluatePIN(ev *accessEvaluation, req AccessRequest) error {
+	if !ev.room.RequiresPIN && ev.card.CredentialType != models.CredentialPIN {
+		return nil
+	}
+
+	var user models.User
+	if err := acs.db.First(&user, ev.card.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			ev.step("pin", false, "A kártyához nem tartozik felhasználó")
+			ev.deny(models.DenialReasonPINRequired)
+			return nil
+		}
+		return err
+	}
+
+	switch {
+	case !user.HasPIN():
+		ev.step("pin", false, "A felhasználónak nincs PIN kódja beállítva")
+		ev.deny(models.DenialReasonPINRequired)
+	case user.PINLockedAt(req.Time):
+		ev.step("pin", false, "A PIN kód zárolva eddig: "+user.PINLockedUntil.In(req.Time.Location()).Format(time.RFC3339))
+		ev.deny(models.DenialReasonPINLocked)
+	case req.PIN == "":
+		ev.step("pin", false, "A helyiség PIN kódot is kér")
+		ev.deny(models.DenialReasonPINRequired)
+	case !user.CheckPIN(req.PIN):
+		ev.step("pin", false, "Hibás PIN kód")
+		ev.deny(models.DenialReasonInvalidPIN)
+		ev.pinFailed = true
+	default:
+		ev.step("pin", true, "")
+		ev.pinReset = user.FailedPINAttempts > 0
+	}
+
+	return nil
+}
+
+// evaluateDualAuthorization looks for a pending tap of another user in the
+// room within the window. Without one this tap becomes the pending one.
+// Exits are not held back.
+func (acs *AccessControlService) evaluateDualAuthorization(ev *accessEvaluation, req AccessRequest) error {
+	if !ev.room.DualAuthorization || req.Direction == models.DirectionExit {
+		return nil
+	}
+
+	window := ev.room.DualAuthorizationTimeout()
+
+	var pending models.Log
+	err := acs.db.
+		Joins("JOIN cards ON cards.id = logs.card_id").
+		Where("logs.room_id = ? AND logs.denial_reason = ? AND logs.linked_log_id IS NULL", ev.room.ID, models.DenialReasonDualAuthPending).
+		Where("logs.timestamp >= ? AND logs.timestamp <= ?", req.Time.Add(-window).Local(), req.Time.Local()).
+		Where("cards.user_id <> ?", ev.card.UserID).
+		Order("logs.timestamp DESC").
+		First(&pending).Error
+
+	if err == gorm.ErrRecordNotFound {
+		ev.step("dual_authorization", false, fmt.Sprintf("Második jogosult személy érintésére vár (%d mp)", int(window.Seconds())))
+		ev.deny(models.DenialReasonDualAuthPending)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ev.step("dual_authorization", true, fmt.Sprintf("Párosítva a #%d naplóbejegyzéssel", pending.ID))
+	ev.dualPartner = &pending
+	return nil
+}
+
+func (ev *accessEvaluation) addPermission(perm models.Permission, source string, at time.Time) {
+	reason := perm.InvalidReason(at)
+
+	ev.trace.Permissions = append(ev.trace.Permissions, PermissionVerdict{
+		PermissionID:    perm.ID,
+		Source:          source,
+		ValidFrom:       perm.ValidFrom,
+		ValidUntil:      perm.ValidUntil,
+		TimeRestriction: perm.TimeRestriction,
+		Valid:           reason == "",
+		Reason:          reason,
+	})
+
+	if reason == "" {
+		ev.granted = true
+	} else if reason == models.ValidityOutsideTimeRestriction {
+		ev.timeRestricted = true
+	}
+}
+
+func (acs *AccessControlService) evaluateGroups(ev *accessEvaluation, userID uint, roomID uint, at time.Time) error {
+	chains, err := acs.groups.UserGroupChains(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, groupChain := range chains {
+		verdict := GroupPathVerdict{Reason: "no_grant"}
+		for _, group := range groupChain {
+			verdict.Path = append(verdict.Path, group.Name)
+			verdict.GroupIDs = append(verdict.GroupIDs, group.ID)
+		}
+
+		var groupRooms []models.GroupRoom
+		if err := acs.db.Where("group_id IN ? AND room_id = ?", verdict.GroupIDs, roomID).Find(&groupRooms).Error; err != nil {
+			return err
+		}
+
+		for _, groupRoom := range groupRooms {
+			reason := groupRoom.InvalidReason(at)
+			if verdict.GrantGroupID != nil && (verdict.Valid || reason != "") {
+				continue
+			}
+
+			grantGroupID := groupRoom.GroupID
+			verdict.GrantGroupID = &grantGroupID
+			verdict.ValidFrom = groupRoom.ValidFrom
+			verdict.ValidUntil = groupRoom.ValidUntil
+			verdict.Valid = reason == ""
+			verdict.Reason = reason
+
+			if reason == models.ValidityOutsideTimeRestriction {
+				ev.timeRestricted = true
+			}
+		}
+
+		if verdict.Valid {
+			ev.granted = true
+		}
+
+		ev.trace.GroupPaths = append(ev.trace.GroupPaths, verdict)
+	}
+
+	return nil
+}
+
+func (acs *AccessControlService) Explain(req AccessRequest) (*AccessTrace, error) {
+	ev, err := acs.evaluate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ev.trace, nil
+}
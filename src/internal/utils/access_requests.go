@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/websocket"
+)
+
+var (
+	ErrAccessRequestInvalidWindow = errors.New("a hozzáférés vége a kezdete után és a jövőben kell legyen")
+	ErrAccessRequestRoomMissing   = errors.New("a megadott helyiség nem létezik")
+	ErrAccessRequestNotPending    = errors.New("a kérelemről már döntöttek")
+	ErrAccessRequestOwn           = errors.New("saját kérelemről nem lehet dönteni")
+	ErrNotApprover                = errors.New("a felhasználó nem jóváhagyója a helyiségnek")
+)
+
+type AccessRequestInput struct {
+	RoomID        uint       `json:"room_id" binding:"required"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until"`
+	Justification string     `json:"justification" binding:"required"`
+}
+
+// AccessRequestService routes access requests to the approvers of the room
+// and of its building. Users holding permissions.write for the building can
+// decide them as well, so a room without approvers is not stuck.
+type AccessRequestService struct {
+	db        *gorm.DB
+	wsHandler *websocket.WebSocketHandler
+	wsEnabled bool
+}
+
+func NewAccessRequestService(db *gorm.DB) *AccessRequestService {
+	return &AccessRequestService{
+		db:        db,
+		wsEnabled: false,
+	}
+}
+
+func (s *AccessRequestService) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	s.wsHandler = wsHandler
+	s.wsEnabled = (wsHandler != nil)
+}
+
+func (s *AccessRequestService) Create(requesterID uint, input AccessRequestInput) (*models.AccessRequest, error) {
+	var room models.Room
+	if err := s.db.First(&room, input.RoomID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAccessRequestRoomMissing
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	validFrom := now
+	if input.ValidFrom != nil && input.ValidFrom.After(now) {
+		validFrom = *input.ValidFrom
+	}
+	if input.ValidUntil != nil && (!input.ValidUntil.After(validFrom) || !input.ValidUntil.After(now)) {
+		return nil, ErrAccessRequestInvalidWindow
+	}
+
+	request := models.AccessRequest{
+		RequesterID:   requesterID,
+		RoomID:        room.ID,
+		ValidFrom:     validFrom,
+		ValidUntil:    input.ValidUntil,
+		Justification: input.Justification,
+		Status:        models.AccessRequestPending,
+	}
+	if err := s.db.Create(&request).Error; err != nil {
+		return nil, err
+	}
+
+	s.db.Preload("Requester").Preload("Room").First(&request, request.ID)
+	s.notify(request, "created")
+
+	return &request, nil
+}
+
+// Approvers returns the approvers of the room and of its building.
+func (s *AccessRequestService) Approvers(room models.Room) ([]models.User, error) {
+	var approvers []models.User
+	if err := s.db.Model(&room).Association("Approvers").Find(&approvers); err != nil {
+		return nil, err
+	}
+
+	if room.BuildingID != nil {
+		var buildingApprovers []models.User
+		building := models.Building{ID: *room.BuildingID}
+		if err := s.db.Model(&building).Association("Approvers").Find(&buildingApprovers); err != nil {
+			return nil, err
+		}
+
+		for _, approver := range buildingApprovers {
+			listed := false
+			for _, existing := range approvers {
+				if existing.ID == approver.ID {
+					listed = true
+					break
+				}
+			}
+			if !listed {
+				approvers = append(approvers, approver)
+			}
+		}
+	}
+
+	return approvers, nil
+}
+
+// CanDecide needs the roles and buildings of the user preloaded.
+func (s *AccessRequestService) CanDecide(user models.User, room models.Room) (bool, error) {
+	if user.HasPrivilege(models.PrivilegePermissionsWrite) && NewBuildingScope(user).AllowsRoom(room) {
+		return true, nil
+	}
+
+	approvers, err := s.Approvers(room)
+	if err != nil {
+		return false, err
+	}
+	for _, approver := range approvers {
+		if approver.ID == user.ID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Decidable restricts a query on access_requests to the ones the user can
+// decide.
+func (s *AccessRequestService) Decidable(query *gorm.DB, user models.User) *gorm.DB {
+	if user.HasPrivilege(models.PrivilegePermissionsWrite) {
+		return NewBuildingScope(user).RoomColumn(s.db, query, "room_id")
+	}
+
+	return query.Where("room_id IN (?) OR room_id IN (?)",
+		s.db.Table("room_approvers").Select("room_id").Where("user_id = ?", user.ID),
+		s.db.Model(&models.Room{}).Select("id").Where("building_id IN (?)",
+			s.db.Table("building_approvers").Select("building_id").Where("user_id = ?", user.ID)))
+}
+
+// Approve grants the requester the room for the requested period, with the
+// approver recorded as the grantor.
+func (s *AccessRequestService) Approve(id uint, approver models.User, note string) (*models.AccessRequest, error) {
+	return s.decide(id, approver, note, func(tx *gorm.DB, request *models.AccessRequest) error {
+		if request.ValidUntil != nil && !request.ValidUntil.After(time.Now()) {
+			return ErrAccessRequestInvalidWindow
+		}
+
+		requesterID := request.RequesterID
+		permission := models.Permission{
+			UserID:     &requesterID,
+			RoomID:     request.RoomID,
+			GrantedBy:  approver.ID,
+			ValidFrom:  request.ValidFrom,
+			ValidUntil: request.ValidUntil,
+			Active:     true,
+		}
+		if err := tx.Create(&permission).Error; err != nil {
+			return err
+		}
+
+		request.Status = models.AccessRequestApproved
+		request.PermissionID = &permission.ID
+		return nil
+	})
+}
+
+func (s *AccessRequestService) Reject(id uint, approver models.User, note string) (*models.AccessRequest, error) {
+	return s.decide(id, approver, note, func(tx *gorm.DB, request *models.AccessRequest) error {
+		request.Status = models.AccessRequestRejected
+		return nil
+	})
+}
+
+// Cancel withdraws a pending request of the requester.
+func (s *AccessRequestService) Cancel(id uint, requesterID uint) (*models.AccessRequest, error) {
+	var request models.AccessRequest
+	if err := s.db.Where("requester_id = ?", requesterID).First(&request, id).Error; err != nil {
+		return nil, err
+	}
+	if request.Status != models.AccessRequestPending {
+		return nil, ErrAccessRequestNotPending
+	}
+
+	if err := s.db.Model(&request).Update("status", models.AccessRequestCancelled).Error; err != nil {
+		return nil, err
+	}
+
+	s.db.Preload("Requester").Preload("Room").First(&request, request.ID)
+	s.notify(request, "cancelled")
+
+	return &request, nil
+}
+
+func (s *AccessRequestService) decide(id uint, approver models.User, note string, apply func(tx *gorm.DB, request *models.AccessRequest) error) (*models.AccessRequest, error) {
+	var request models.AccessRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Room").First(&request, id).Error; err != nil {
+			return err
+		}
+		if request.Status != models.AccessRequestPending {
+			return ErrAccessRequestNotPending
+		}
+		if request.RequesterID == approver.ID {
+			return ErrAccessRequestOwn
+		}
+
+		allowed, err := s.CanDecide(approver, request.Room)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrNotApprover
+		}
+
+		if err := apply(tx, &request); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		request.DecidedBy = &approver.ID
+		request.DecidedAt = &now
+		request.DecisionNote = note
+
+		return tx.Model(&request).Updates(map[string]interface{}{
+			"status":        request.Status,
+			"decided_by":    request.DecidedBy,
+			"decided_at":    now,
+			"decision_note": note,
+			"permission_id": request.PermissionID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.Preload("Requester").Preload("Room").First(&request, request.ID)
+	s.notify(request, string(request.Status))
+
+	return &request, nil
+}
+
+// notify tells the requester, the approvers and the admins about the
+// request.
+func (s *AccessRequestService) notify(request models.AccessRequest, action string) {
+	if !s.wsEnabled {
+		return
+	}
+
+	event := map[string]interface{}{
+		"action": action,
+		"request": map[string]interface{}{
+			"id":     request.ID,
+			"status": request.Status,
+			"room": map[string]interface{}{
+				"id":          request.Room.ID,
+				"name":        request.Room.Name,
+				"building":    request.Room.Building,
+				"room_number": request.Room.RoomNumber,
+			},
+			"requester": map[string]interface{}{
+				"id":   request.Requester.ID,
+				"name": request.Requester.FullName(),
+			},
+			"valid_from":    request.ValidFrom,
+			"valid_until":   request.ValidUntil,
+			"justification": request.Justification,
+			"decided_by":    request.DecidedBy,
+			"decision_note": request.DecisionNote,
+		},
+	}
+
+	hub := s.wsHandler.GetHub()
+	hub.BroadcastToUser(request.RequesterID, "access_request_event", event)
+
+	approvers, err := s.Approvers(request.Room)
+	if err == nil {
+		for _, approver := range approvers {
+			// Admins get the event below.
+			if approver.ID != request.RequesterID && !approver.IsAdmin {
+				hub.BroadcastToUser(approver.ID, "access_request_event", event)
+			}
+		}
+	}
+
+	hub.BroadcastToAdmins("access_request_event", event)
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+const apiKeyPrefixLength = 8
+
+var (
+	ErrAPIKeyInvalid = errors.New("érvénytelen API kulcs")
+	ErrAPIKeyRevoked = errors.New("visszavont API kulcs")
+	ErrAPIKeyExpired = errors.New("lejárt API kulcs")
+)
+
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// Create stores a new key and returns it together with the plain key, which
+// is not recoverable afterwards.
+func (s *APIKeyService) Create(name string, deviceID *uint, createdBy uint, expiresAt *time.Time) (*models.APIKey, string, error) {
+	return s.create(s.db, name, deviceID, createdBy, expiresAt)
+}
+
+func (s *APIKeyService) create(tx *gorm.DB, name string, deviceID *uint, createdBy uint, expiresAt *time.Time) (*models.APIKey, string, error) {
+	plain, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := models.APIKey{
+		Name:      name,
+		Prefix:    plain[:apiKeyPrefixLength],
+		KeyHash:   HashAPIKey(plain),
+		DeviceID:  deviceID,
+		CreatedBy: createdBy,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := tx.Create(&key).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &key, plain, nil
+}
+
+// Import stores a key chosen outside the service, such as one of the former
+// API_KEYS, unless a key with the same hash exists already, revoked ones
+// included. The result is nil when the key was not stored.
+func (s *APIKeyService) Import(name string, plain string) (*models.APIKey, error) {
+	hash := HashAPIKey(plain)
+
+	var existing int64
+	if err := s.db.Model(&models.APIKey{}).Where("key_hash = ?", hash).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, nil
+	}
+
+	// Imported keys may be short, the prefix must not give them away.
+	prefixLength := apiKeyPrefixLength
+	if len(plain)/2 < prefixLength {
+		prefixLength = len(plain) / 2
+	}
+
+	key := models.APIKey{
+		Name:    name,
+		Prefix:  plain[:prefixLength],
+		KeyHash: hash,
+	}
+
+	if err := s.db.Create(&key).Error; err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// Authenticate looks the key up by its hash and records its use.
+func (s *APIKeyService) Authenticate(plain string, ip string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("key_hash = ?", HashAPIKey(plain)).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAPIKeyInvalid
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if !key.IsUsableAt(now) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	s.db.Model(&key).Updates(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": ip,
+	})
+
+	return &key, nil
+}
+
+func (s *APIKeyService) Revoke(key *models.APIKey, revokedBy uint) error {
+	now := time.Now()
+	key.RevokedAt = &now
+	key.RevokedBy = &revokedBy
+
+	return s.db.Model(key).Updates(map[string]interface{}{
+		"revoked_at": now,
+		"revoked_by": revokedBy,
+	}).Error
+}
+
+func (s *APIKeyService) RevokeDeviceKeys(deviceID uint, revokedBy uint) error {
+	return s.db.Model(&models.APIKey{}).
+		Where("device_id = ? AND revoked_at IS NULL", deviceID).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now(),
+			"revoked_by": revokedBy,
+		}).Error
+}
+
+// Rotate issues a replacement with the same name and scope. The old key
+// keeps working for the grace period so the reader can be reconfigured, with
+// no grace period it is revoked at once.
+func (s *APIKeyService) Rotate(old *models.APIKey, rotatedBy uint, expiresAt *time.Time, grace time.Duration) (*models.APIKey, string, error) {
+	var key *models.APIKey
+	var plain string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		key, plain, err = s.create(tx, old.Name, old.DeviceID, rotatedBy, expiresAt)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"replaced_by_id": key.ID}
+		if grace > 0 {
+			oldExpiry := time.Now().Add(grace)
+			if old.ExpiresAt == nil || oldExpiry.Before(*old.ExpiresAt) {
+				updates["expires_at"] = oldExpiry
+			}
+		} else {
+			updates["revoked_at"] = time.Now()
+			updates["revoked_by"] = rotatedBy
+		}
+
+		return tx.Model(old).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, plain, nil
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+var ErrBuildingOutOfScope = errors.New("az épület kívül esik a kezelt épületeken")
+
+// BuildingScope limits a delegated administrator to the rooms of the
+// buildings assigned to them. A nil scope covers every building.
+type BuildingScope struct {
+	BuildingIDs []uint
+}
+
+// NewBuildingScope needs the buildings of the user preloaded. Users without
+// assigned buildings get a nil scope.
+func NewBuildingScope(user models.User) *BuildingScope {
+	if len(user.Buildings) == 0 {
+		return nil
+	}
+
+	scope := &BuildingScope{}
+	for _, building := range user.Buildings {
+		scope.BuildingIDs = append(scope.BuildingIDs, building.ID)
+	}
+	return scope
+}
+
+func (s *BuildingScope) AllowsBuilding(buildingID uint) bool {
+	if s == nil {
+		return true
+	}
+	for _, id := range s.BuildingIDs {
+		if id == buildingID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *BuildingScope) AllowsRoom(room models.Room) bool {
+	if s == nil {
+		return true
+	}
+	return room.BuildingID != nil && s.AllowsBuilding(*room.BuildingID)
+}
+
+// Rooms restricts a query on the rooms table.
+func (s *BuildingScope) Rooms(query *gorm.DB) *gorm.DB {
+	if s == nil {
+		return query
+	}
+	return query.Where("building_id IN ?", s.BuildingIDs)
+}
+
+// RoomColumn restricts a query to the rows whose column references a room
+// in scope, e.g. "logs.room_id".
+func (s *BuildingScope) RoomColumn(db *gorm.DB, query *gorm.DB, column string) *gorm.DB {
+	if s == nil {
+		return query
+	}
+	return query.Where(column+" IN (?)", db.Model(&models.Room{}).Select("id").Where("building_id IN ?", s.BuildingIDs))
+}
+
+// ResolveBuilding finds the building of a room by ID or by name. A name not
+// seen before creates the building, unless the scope is limited.
+func ResolveBuilding(db *gorm.DB, scope *BuildingScope, id *uint, name string) (*models.Building, error) {
+	var building models.Building
+
+	if id != nil {
+		if err := db.First(&building, *id).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		err := db.Where("name = ?", name).First(&building).Error
+		if err == gorm.ErrRecordNotFound {
+			if scope != nil {
+				return nil, ErrBuildingOutOfScope
+			}
+			building = models.Building{Name: name}
+			err = db.Create(&building).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !scope.AllowsBuilding(building.ID) {
+		return nil, ErrBuildingOutOfScope
+	}
+	return &building, nil
+}
+
+// LinkRoomBuildings points the rooms still identified only by their building
+// name at the matching building, creating the missing buildings.
+// Emergencies declared before buildings had IDs are linked as well.
+func LinkRoomBuildings(db *gorm.DB) error {
+	var names []string
+	if err := db.Model(&models.Room{}).Where("building_id IS NULL AND building <> ''").Distinct().Pluck("building", &names).Error; err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		building, err := ResolveBuilding(db, nil, nil, name)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Room{}).Where("building_id IS NULL AND building = ?", name).Update("building_id", building.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	return db.Model(&models.EmergencyState{}).
+		Where("building_id IS NULL AND building <> ''").
+		Update("building_id", db.Model(&models.Building{}).Select("id").Where("buildings.name = emergency_states.building")).Error
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+var (
+	ErrCalendarInvalidType   = errors.New("érvénytelen kivétel típus vagy hatókör")
+	ErrCalendarInvalidDates  = errors.New("érvénytelen dátum tartomány")
+	ErrCalendarInvalidHours  = errors.New("érvénytelen időablak")
+	ErrCalendarMissingTarget = errors.New("a hatókörhöz tartozó helyiség vagy épület hiányzik")
+)
+
+type CalendarService struct {
+	db *gorm.DB
+}
+
+func NewCalendarService(db *gorm.DB) *CalendarService {
+	return &CalendarService{
+		db: db,
+	}
+}
+
+// Validate checks an exception before it is stored and clears the target
+// fields that do not belong to its scope.
+func (s *CalendarService) Validate(exception *models.CalendarException) error {
+	if !exception.Type.IsValid() || !exception.Scope.IsValid() {
+		return ErrCalendarInvalidType
+	}
+
+	start, err := time.Parse(models.CalendarDateLayout, exception.StartDate)
+	if err != nil {
+		return ErrCalendarInvalidDates
+	}
+	if exception.EndDate == "" {
+		exception.EndDate = exception.StartDate
+	}
+	end, err := time.Parse(models.CalendarDateLayout, exception.EndDate)
+	if err != nil || end.Before(start) {
+		return ErrCalendarInvalidDates
+	}
+
+	if exception.Hours != "" {
+		if _, err := models.ParseSchedule(exception.Hours); err != nil {
+			return fmt.Errorf("%w (%v)", ErrCalendarInvalidHours, err)
+		}
+	}
+
+	switch exception.Scope {
+	case models.CalendarScopeCampus:
+		exception.RoomID = nil
+		exception.Building = ""
+	case models.CalendarScopeBuilding:
+		exception.RoomID = nil
+		if exception.Building == "" {
+			return ErrCalendarMissingTarget
+		}
+	case models.CalendarScopeRoom:
+		exception.Building = ""
+		if exception.RoomID == nil {
+			return ErrCalendarMissingTarget
+		}
+		var count int64
+		if err := s.db.Model(&models.Room{}).Where("id = ?", *exception.RoomID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrCalendarMissingTarget
+		}
+	}
+
+	return nil
+}
+
+// InScope reports whether a delegated administrator may manage the
+// exception. Campus wide exceptions close every building, so they are left
+// to unrestricted administrators.
+func (s *CalendarService) InScope(scope *BuildingScope, exception models.CalendarException) (bool, error) {
+	if scope == nil {
+		return true, nil
+	}
+
+	switch exception.Scope {
+	case models.CalendarScopeBuilding:
+		var building models.Building
+		if err := s.db.Where("name = ?", exception.Building).First(&building).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return false, nil
+			}
+			return false, err
+		}
+		return scope.AllowsBuilding(building.ID), nil
+	case models.CalendarScopeRoom:
+		if exception.RoomID == nil {
+			return false, nil
+		}
+		var room models.Room
+		if err := s.db.First(&room, *exception.RoomID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return false, nil
+			}
+			return false, err
+		}
+		return scope.AllowsRoom(room), nil
+	}
+
+	return false, nil
+}
+
+// ExceptionsOn returns the exceptions covering the room on any day between
+// from and to (inclusive, YYYY-MM-DD).
+func (s *CalendarService) ExceptionsOn(room models.Room, from, to string) ([]models.CalendarException, error) {
+	var candidates []models.CalendarException
+	if err := s.db.Where("start_date <= ? AND end_date >= ?", to, from).
+		Order("start_date").Order("id").
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var exceptions []models.CalendarException
+	for _, exception := range candidates {
+		if exception.Covers(room) {
+			exceptions = append(exceptions, exception)
+		}
+	}
+
+	return exceptions, nil
+}
+
+// ExceptionAt returns the exception deciding whether the room is open at t,
+// or nil when the weekly hours apply. The most specific scope wins, on equal
+// scope a closure beats an extra opening.
+func (s *CalendarService) ExceptionAt(room models.Room, t time.Time) (*models.CalendarException, error) {
+	date := t.Format(models.CalendarDateLayout)
+
+	exceptions, err := s.ExceptionsOn(room, date, date)
+	if err != nil {
+		return nil, err
+	}
+
+	var decisive *models.CalendarException
+	for i := range exceptions {
+		exception := &exceptions[i]
+		if !exception.AppliesAt(t) {
+			continue
+		}
+
+		if decisive == nil {
+			decisive = exception
+			continue
+		}
+
+		current, candidate := decisive.Scope.Specificity(), exception.Scope.Specificity()
+		if candidate > current || (candidate == current && exception.Closes() && !decisive.Closes()) {
+			decisive = exception
+		}
+	}
+
+	return decisive, nil
+}
+
+type CalendarImportSkip struct {
+	UID   string `json:"uid"`
+	Error string `json:"error"`
+}
+
+type CalendarImportResult struct {
+	Imported int                  `json:"imported"`
+	Updated  int                  `json:"updated"`
+	Skipped  []CalendarImportSkip `json:"skipped"`
+}
+
+// Import stores the events as exceptions shaped after template. Events are
+// matched on their UID, so importing the same calendar again updates the
+// earlier exceptions instead of duplicating them.
+func (s *CalendarService) Import(events []ICalEvent, template models.CalendarException) (*CalendarImportResult, error) {
+	result := &CalendarImportResult{Skipped: []CalendarImportSkip{}}
+
+	for _, event := range events {
+		exception := template
+		exception.StartDate = event.StartDate
+		exception.EndDate = event.EndDate
+		exception.Hours = event.Hours
+		exception.Reason = event.Summary
+		exception.Source = "ical"
+		exception.ExternalUID = event.UID
+
+		if exception.Reason == "" {
+			exception.Reason = "iCalendar import"
+		}
+
+		if err := s.Validate(&exception); err != nil {
+			result.Skipped = append(result.Skipped, CalendarImportSkip{UID: event.UID, Error: err.Error()})
+			continue
+		}
+
+		var existing models.CalendarException
+		err := gorm.ErrRecordNotFound
+		if event.UID != "" {
+			err = s.db.Where("source = ? AND external_uid = ?", "ical", event.UID).First(&existing).Error
+		}
+
+		switch err {
+		case nil:
+			exception.ID = existing.ID
+			exception.CreatedAt = existing.CreatedAt
+			exception.CreatedBy = existing.CreatedBy
+			if err := s.db.Save(&exception).Error; err != nil {
+				return nil, err
+			}
+			result.Updated++
+		case gorm.ErrRecordNotFound:
+			if err := s.db.Create(&exception).Error; err != nil {
+				return nil, err
+			}
+			result.Imported++
+		default:
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+// findCredential resolves a presented credential to its card. Without a type
+// any credential with the identifier matches.
+func findCredential(db *gorm.DB, credentialType models.CredentialType, value string) (*models.Card, error) {
+	query := db.Preload("Permissions").Preload("User").Where("card_id = ?", value)
+
+	if credentialType != "" {
+		query = query.Where("credential_type = ?", credentialType)
+	}
+
+	var card models.Card
+	if err := query.First(&card).Error; err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
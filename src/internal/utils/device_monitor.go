@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/websocket"
+)
+
+const (
+	DeviceStatusOnline    = "online"
+	DeviceStatusOffline   = "offline"
+	DeviceStatusNeverSeen = "never_seen"
+	DeviceStatusDisabled  = "disabled"
+)
+
+type DeviceHealth struct {
+	Device           models.Device `json:"device"`
+	Status           string        `json:"status"`
+	SecondsSinceSeen *int64        `json:"seconds_since_seen"`
+}
+
+type DeviceHealthReport struct {
+	Threshold int64          `json:"threshold_seconds"`
+	Summary   map[string]int `json:"summary"`
+	Tampered  int            `json:"tampered"`
+	Devices   []DeviceHealth `json:"devices"`
+}
+
+// DeviceMonitor keeps track of reader heartbeats and reports readers that
+// went silent or were tampered with as system events.
+type DeviceMonitor struct {
+	db        *gorm.DB
+	devices   *DeviceService
+	threshold time.Duration
+	wsHandler *websocket.WebSocketHandler
+	wsEnabled bool
+}
+
+func NewDeviceMonitor(db *gorm.DB, threshold time.Duration) *DeviceMonitor {
+	return &DeviceMonitor{
+		db:        db,
+		devices:   NewDeviceService(db),
+		threshold: threshold,
+		wsEnabled: false,
+	}
+}
+
+func (m *DeviceMonitor) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	m.wsHandler = wsHandler
+	m.wsEnabled = (wsHandler != nil)
+}
+
+func (m *DeviceMonitor) ResolveReader(keyDeviceID *uint, deviceID string) (*models.Device, error) {
+	return m.devices.ResolveReader(keyDeviceID, deviceID)
+}
+
+func (m *DeviceMonitor) Heartbeat(device *models.Device, heartbeat models.DeviceHeartbeat, ip string) error {
+	previous, err := m.devices.RecordHeartbeat(device, heartbeat, ip)
+	if err != nil {
+		return err
+	}
+
+	if !previous.Online && previous.LastSeenAt != nil {
+		m.systemEvent("info", device, "Az olvasó ismét elérhető")
+	}
+	if heartbeat.Tampered && !previous.Tampered {
+		m.systemEvent("critical", device, "Az olvasó szabotázsjelzést küldött")
+	}
+	if !heartbeat.Tampered && previous.Tampered {
+		m.systemEvent("info", device, "Az olvasó szabotázsjelzése megszűnt")
+	}
+
+	return nil
+}
+
+// Run checks for silent readers until the process exits.
+func (m *DeviceMonitor) Run() {
+	interval := m.threshold / 3
+	if interval < 5*time.Second {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := m.CheckStale(); err != nil {
+			log.Printf("Olvasók állapotának ellenőrzése sikertelen: %v", err)
+		}
+	}
+}
+
+func (m *DeviceMonitor) CheckStale() error {
+	stale, err := m.devices.StaleDevices(m.threshold)
+	if err != nil {
+		return err
+	}
+
+	for i := range stale {
+		device := &stale[i]
+		if err := m.devices.MarkOffline(device); err != nil {
+			return err
+		}
+
+		m.systemEvent("warning", device, fmt.Sprintf("Az olvasó %s óta nem jelentkezett", device.LastSeenAt.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+func (m *DeviceMonitor) Health() (*DeviceHealthReport, error) {
+	var devices []models.Device
+	if err := m.db.Preload("Room").Order("name").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+
+	report := &DeviceHealthReport{
+		Threshold: int64(m.threshold.Seconds()),
+		Summary: map[string]int{
+			DeviceStatusOnline:    0,
+			DeviceStatusOffline:   0,
+			DeviceStatusNeverSeen: 0,
+			DeviceStatusDisabled:  0,
+		},
+		Devices: make([]DeviceHealth, 0, len(devices)),
+	}
+
+	now := time.Now()
+	for _, device := range devices {
+		health := DeviceHealth{Device: device}
+
+		if device.LastSeenAt != nil {
+			seconds := int64(now.Sub(*device.LastSeenAt).Seconds())
+			health.SecondsSinceSeen = &seconds
+		}
+
+		switch {
+		case !device.Enabled:
+			health.Status = DeviceStatusDisabled
+		case device.LastSeenAt == nil:
+			health.Status = DeviceStatusNeverSeen
+		case now.Sub(*device.LastSeenAt) > m.threshold:
+			health.Status = DeviceStatusOffline
+		default:
+			health.Status = DeviceStatusOnline
+		}
+
+		report.Summary[health.Status]++
+		if device.Tampered {
+			report.Tampered++
+		}
+		report.Devices = append(report.Devices, health)
+	}
+
+	return report, nil
+}
+
+func (m *DeviceMonitor) systemEvent(severity string, device *models.Device, message string) {
+	text := fmt.Sprintf("%s (%s): %s", device.Name, device.DeviceID, message)
+	log.Printf("Olvasó esemény [%s] %s", severity, text)
+
+	if !m.wsEnabled {
+		return
+	}
+
+	m.wsHandler.GetHub().BroadcastSystemEvent(websocket.SystemEvent{
+		Message:   text,
+		Severity:  severity,
+		Source:    "device:" + device.DeviceID,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}, true)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+var (
+	ErrDeviceUnknown  = errors.New("ismeretlen olvasó")
+	ErrDeviceDisabled = errors.New("az olvasó le van tiltva")
+	ErrDeviceMismatch = errors.New("az olvasó nem ehhez a helyiséghez vagy irányhoz tartozik")
+)
+
+type DeviceService struct {
+	db *gorm.DB
+}
+
+func NewDeviceService(db *gorm.DB) *DeviceService {
+	return &DeviceService{db: db}
+}
+
+func (s *DeviceService) Resolve(deviceID string) (*models.Device, error) {
+	return s.resolve(s.db.Where("device_id = ?", deviceID))
+}
+
+func (s *DeviceService) ResolveByID(id uint) (*models.Device, error) {
+	return s.resolve(s.db.Where("id = ?", id))
+}
+
+func (s *DeviceService) resolve(query *gorm.DB) (*models.Device, error) {
+	var device models.Device
+	if err := query.First(&device).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrDeviceUnknown
+		}
+		return nil, err
+	}
+
+	if !device.Enabled {
+		return &device, ErrDeviceDisabled
+	}
+
+	return &device, nil
+}
+
+// ResolveReader identifies the calling reader. A device scoped API key pins
+// the reader, a device ID sent alongside it has to name the same device.
+func (s *DeviceService) ResolveReader(keyDeviceID *uint, deviceID string) (*models.Device, error) {
+	if keyDeviceID != nil {
+		device, err := s.ResolveByID(*keyDeviceID)
+		if err != nil {
+			return device, err
+		}
+		if deviceID != "" && deviceID != device.DeviceID {
+			return device, ErrDeviceMismatch
+		}
+		return device, nil
+	}
+
+	if deviceID == "" {
+		return nil, ErrDeviceUnknown
+	}
+
+	return s.Resolve(deviceID)
+}
+
+// RecordHeartbeat stores the reported state and returns the device as it was
+// before the heartbeat, so callers can tell state changes apart.
+func (s *DeviceService) RecordHeartbeat(device *models.Device, heartbeat models.DeviceHeartbeat, ip string) (models.Device, error) {
+	previous := *device
+	now := time.Now()
+
+	updates := map[string]interface{}{
+		"online":           true,
+		"last_seen_at":     now,
+		"last_seen_ip":     ip,
+		"firmware_version": heartbeat.FirmwareVersion,
+		"uptime_seconds":   heartbeat.UptimeSeconds,
+		"tampered":         heartbeat.Tampered,
+	}
+
+	if err := s.db.Model(device).Updates(updates).Error; err != nil {
+		return previous, err
+	}
+
+	return previous, nil
+}
+
+// StaleDevices returns the enabled readers still marked online whose last
+// heartbeat is older than the threshold.
+func (s *DeviceService) StaleDevices(threshold time.Duration) ([]models.Device, error) {
+	var devices []models.Device
+	err := s.db.Preload("Room").
+		Where("enabled = ? AND online = ? AND last_seen_at < ?", true, true, time.Now().Add(-threshold)).
+		Find(&devices).Error
+
+	return devices, err
+}
+
+func (s *DeviceService) MarkOffline(device *models.Device) error {
+	return s.db.Model(device).Update("online", false).Error
+}
+
+// BindRequest checks that the device may ask about the requested room and
+// direction, filling in whichever of the two the reader left out.
+func (s *DeviceService) BindRequest(device *models.Device, req *AccessRequest) error {
+	if req.RoomID == 0 {
+		req.RoomID = device.RoomID
+	}
+	if req.Direction == "" {
+		req.Direction = device.Direction
+	}
+
+	if !device.Serves(req.RoomID, req.Direction) {
+		return ErrDeviceMismatch
+	}
+
+	req.DeviceID = device.DeviceID
+	return nil
+}
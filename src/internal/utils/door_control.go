@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/websocket"
+)
+
+const doorCommandAckTimeout = 5 * time.Second
+
+var (
+	ErrDoorControlUnavailable = errors.New("a WebSocket kapcsolat nincs engedélyezve")
+	ErrNoRoomDevices          = errors.New("a helyiségben nincs engedélyezett olvasó")
+)
+
+type DoorCommandDelivery struct {
+	DeviceID     string `json:"device_id"`
+	CommandID    string `json:"command_id,omitempty"`
+	Delivered    bool   `json:"delivered"`
+	Acknowledged bool   `json:"acknowledged"`
+	Success      bool   `json:"success"`
+	Error        string `json:"error,omitempty"`
+}
+
+type DoorCommandResult struct {
+	RoomID          uint                  `json:"room_id"`
+	Command         string                `json:"command"`
+	DurationSeconds int                   `json:"duration_seconds,omitempty"`
+	Reason          string                `json:"reason"`
+	ActorUserID     uint                  `json:"actor_user_id"`
+	Timestamp       time.Time             `json:"timestamp"`
+	Deliveries      []DoorCommandDelivery `json:"deliveries"`
+	Acknowledged    int                   `json:"acknowledged"`
+}
+
+// DoorControlService sends manual door commands to the readers of a room and
+// writes every override to the log.
+type DoorControlService struct {
+	db        *gorm.DB
+	wsHandler *websocket.WebSocketHandler
+	wsEnabled bool
+}
+
+func NewDoorControlService(db *gorm.DB) *DoorControlService {
+	return &DoorControlService{
+		db:        db,
+		wsEnabled: false,
+	}
+}
+
+func (s *DoorControlService) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	s.wsHandler = wsHandler
+	s.wsEnabled = (wsHandler != nil)
+}
+
+// Unlock opens the doors of the room, for durationSeconds or momentarily
+// when it is zero.
+func (s *DoorControlService) Unlock(room models.Room, actorID uint, reason string, durationSeconds int) (*DoorCommandResult, error) {
+	params := map[string]interface{}{}
+	if durationSeconds > 0 {
+		params["duration_seconds"] = durationSeconds
+	}
+
+	return s.send(room, actorID, reason, websocket.ReaderCommand{Command: websocket.ReaderCommandUnlock, Params: params}, durationSeconds)
+}
+
+func (s *DoorControlService) Lock(room models.Room, actorID uint, reason string) (*DoorCommandResult, error) {
+	return s.send(room, actorID, reason, websocket.ReaderCommand{Command: websocket.ReaderCommandLock}, 0)
+}
+
+func (s *DoorControlService) send(room models.Room, actorID uint, reason string, command websocket.ReaderCommand, durationSeconds int) (*DoorCommandResult, error) {
+	if !s.wsEnabled {
+		return nil, ErrDoorControlUnavailable
+	}
+
+	var devices []models.Device
+	if err := s.db.Where("room_id = ? AND enabled = ?", room.ID, true).Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, ErrNoRoomDevices
+	}
+
+	result := &DoorCommandResult{
+		RoomID:          room.ID,
+		Command:         command.Command,
+		DurationSeconds: durationSeconds,
+		Reason:          reason,
+		ActorUserID:     actorID,
+		Timestamp:       time.Now(),
+		Deliveries:      make([]DoorCommandDelivery, len(devices)),
+	}
+
+	hub := s.wsHandler.GetHub()
+
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		wg.Add(1)
+		go func(i int, device models.Device) {
+			defer wg.Done()
+			result.Deliveries[i] = deliver(hub, device, command)
+		}(i, device)
+	}
+	wg.Wait()
+
+	eventType := models.LogEventManualUnlock
+	accessResult := models.AccessGranted
+	if command.Command == websocket.ReaderCommandLock {
+		eventType = models.LogEventManualLock
+		accessResult = models.AccessDenied
+	}
+
+	for _, delivery := range result.Deliveries {
+		if delivery.Acknowledged && delivery.Success {
+			result.Acknowledged++
+		}
+
+		actor := actorID
+		entry := models.Log{
+			RoomID:       room.ID,
+			Timestamp:    result.Timestamp,
+			AccessResult: accessResult,
+			DeviceID:     delivery.DeviceID,
+			EventType:    eventType,
+			ActorUserID:  &actor,
+			Description:  fmt.Sprintf("%s (%s)", reason, deliveryState(delivery)),
+		}
+		if err := s.db.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	hub.BroadcastToAdmins("door_event", map[string]interface{}{
+		"room": map[string]interface{}{
+			"id":          room.ID,
+			"name":        room.Name,
+			"building":    room.Building,
+			"room_number": room.RoomNumber,
+		},
+		"command":          result.Command,
+		"duration_seconds": durationSeconds,
+		"reason":           reason,
+		"actor_user_id":    actorID,
+		"acknowledged":     result.Acknowledged,
+		"devices":          len(result.Deliveries),
+		"timestamp":        result.Timestamp.Format(time.RFC3339),
+	})
+
+	return result, nil
+}
+
+func deliver(hub *websocket.Hub, device models.Device, command websocket.ReaderCommand) DoorCommandDelivery {
+	delivery := DoorCommandDelivery{DeviceID: device.DeviceID}
+
+	commandID, ack, err := hub.SendCommand(device.ID, command, doorCommandAckTimeout)
+	delivery.CommandID = commandID
+
+	switch {
+	case err == websocket.ErrReaderNotConnected:
+		delivery.Error = err.Error()
+	case err != nil:
+		delivery.Delivered = true
+		delivery.Error = err.Error()
+	default:
+		delivery.Delivered = true
+		delivery.Acknowledged = ack != nil
+		delivery.Success = ack != nil && ack.Success
+		if ack != nil && ack.Error != "" {
+			delivery.Error = ack.Error
+		}
+	}
+
+	return delivery
+}
+
+func deliveryState(delivery DoorCommandDelivery) string {
+	switch {
+	case !delivery.Delivered:
+		return "az olvasó nem kapcsolódik"
+	case !delivery.Acknowledged:
+		return "nincs visszaigazolás"
+	case !delivery.Success:
+		return "az olvasó hibát jelzett: " + delivery.Error
+	default:
+		return "visszaigazolva"
+	}
+}
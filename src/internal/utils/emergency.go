@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/websocket"
+)
+
+var (
+	ErrEmergencyInvalid       = errors.New("érvénytelen vészhelyzeti mód vagy hatókör")
+	ErrEmergencyTargetMissing = errors.New("a hatókörhöz tartozó helyiség vagy épület hiányzik")
+	ErrEmergencyNotActive     = errors.New("a vészhelyzet már nem aktív")
+)
+
+type EmergencyActivation struct {
+	Mode             models.EmergencyMode  `json:"mode" binding:"required"`
+	Scope            models.EmergencyScope `json:"scope" binding:"required"`
+	RoomID           *uint                 `json:"room_id"`
+	BuildingID       *uint                 `json:"building_id"`
+	Building         string                `json:"building"`
+	ResponderGroupID *uint                 `json:"responder_group_id"`
+	Reason           string                `json:"reason" binding:"required"`
+}
+
+// EmergencyService manages lockdowns and evacuations. Every change is
+// logged per affected room, pushed to the readers of those rooms and
+// broadcast to all websocket clients.
+type EmergencyService struct {
+	db        *gorm.DB
+	wsHandler *websocket.WebSocketHandler
+	wsEnabled bool
+}
+
+func NewEmergencyService(db *gorm.DB) *EmergencyService {
+	return &EmergencyService{
+		db:        db,
+		wsEnabled: false,
+	}
+}
+
+func (s *EmergencyService) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	s.wsHandler = wsHandler
+	s.wsEnabled = (wsHandler != nil)
+}
+
+func (s *EmergencyService) Active() ([]models.EmergencyState, error) {
+	var states []models.EmergencyState
+	err := s.db.Where("active = ?", true).Order("activated_at DESC").Order("id DESC").Find(&states).Error
+	return states, err
+}
+
+// ForRoom returns the most recently activated emergency covering the room,
+// or nil when the room is in normal operation.
+func (s *EmergencyService) ForRoom(room models.Room) (*models.EmergencyState, error) {
+	states, err := s.Active()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range states {
+		if states[i].Covers(room) {
+			return &states[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Activate declares an emergency. A delegated administrator can only
+// declare one for the rooms and buildings of their scope, never for the
+// whole campus.
+func (s *EmergencyService) Activate(input EmergencyActivation, actorID uint, scope *BuildingScope) (*models.EmergencyState, error) {
+	if !input.Mode.IsValid() || !input.Scope.IsValid() {
+		return nil, ErrEmergencyInvalid
+	}
+
+	state := models.EmergencyState{
+		Mode:        input.Mode,
+		Scope:       input.Scope,
+		Reason:      input.Reason,
+		Active:      true,
+		ActivatedBy: actorID,
+		ActivatedAt: time.Now(),
+	}
+
+	switch input.Scope {
+	case models.EmergencyScopeRoom:
+		if input.RoomID == nil {
+			return nil, ErrEmergencyTargetMissing
+		}
+		var room models.Room
+		if err := s.db.First(&room, *input.RoomID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrEmergencyTargetMissing
+			}
+			return nil, err
+		}
+		if !scope.AllowsRoom(room) {
+			return nil, ErrBuildingOutOfScope
+		}
+		state.RoomID = &room.ID
+		state.BuildingID = room.BuildingID
+		state.Building = room.Building
+	case models.EmergencyScopeBuilding:
+		var building models.Building
+		query := s.db
+		switch {
+		case input.BuildingID != nil:
+			query = query.Where("id = ?", *input.BuildingID)
+		case input.Building != "":
+			query = query.Where("name = ?", input.Building)
+		default:
+			return nil, ErrEmergencyTargetMissing
+		}
+		if err := query.First(&building).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrEmergencyTargetMissing
+			}
+			return nil, err
+		}
+		if !scope.AllowsBuilding(building.ID) {
+			return nil, ErrBuildingOutOfScope
+		}
+		state.BuildingID = &building.ID
+		state.Building = building.Name
+	case models.EmergencyScopeCampus:
+		if scope != nil {
+			return nil, ErrBuildingOutOfScope
+		}
+	}
+
+	if input.Mode == models.EmergencyLockdown && input.ResponderGroupID != nil {
+		var group models.Group
+		if err := s.db.First(&group, *input.ResponderGroupID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrEmergencyTargetMissing
+			}
+			return nil, err
+		}
+		state.ResponderGroupID = &group.ID
+	}
+
+	rooms, err := s.affectedRooms(state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Scope == models.EmergencyScopeBuilding && len(rooms) == 0 {
+		return nil, ErrEmergencyTargetMissing
+	}
+
+	if err := s.db.Create(&state).Error; err != nil {
+		return nil, err
+	}
+
+	eventType := models.LogEventLockdown
+	result := models.AccessDenied
+	if state.Mode == models.EmergencyEvacuation {
+		eventType = models.LogEventEvacuation
+		result = models.AccessGranted
+	}
+
+	description := fmt.Sprintf("Vészhelyzet #%d (%s): %s", state.ID, state.Mode, state.Reason)
+	if err := s.logRooms(rooms, eventType, result, actorID, state.ActivatedAt, description); err != nil {
+		return nil, err
+	}
+
+	s.notify(state, rooms, true)
+
+	return &state, nil
+}
+
+// Clear ends an emergency. Like activation it is limited to the scope of a
+// delegated administrator.
+func (s *EmergencyService) Clear(id uint, actorID uint, reason string, scope *BuildingScope) (*models.EmergencyState, error) {
+	var state models.EmergencyState
+	if err := s.db.First(&state, id).Error; err != nil {
+		return nil, err
+	}
+
+	if scope != nil && (state.BuildingID == nil || !scope.AllowsBuilding(*state.BuildingID)) {
+		return nil, ErrBuildingOutOfScope
+	}
+
+	if !state.Active {
+		return nil, ErrEmergencyNotActive
+	}
+
+	now := time.Now()
+	state.Active = false
+	state.ClearedBy = &actorID
+	state.ClearedAt = &now
+	if err := s.db.Save(&state).Error; err != nil {
+		return nil, err
+	}
+
+	rooms, err := s.affectedRooms(state)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Vészhelyzet #%d (%s) feloldva", state.ID, state.Mode)
+	if reason != "" {
+		description += ": " + reason
+	}
+	if err := s.logRooms(rooms, models.LogEventEmergencyEnd, models.AccessGranted, actorID, now, description); err != nil {
+		return nil, err
+	}
+
+	s.notify(state, rooms, false)
+
+	return &state, nil
+}
+
+func (s *EmergencyService) affectedRooms(state models.EmergencyState) ([]models.Room, error) {
+	var rooms []models.Room
+	query := s.db
+
+	switch state.Scope {
+	case models.EmergencyScopeRoom:
+		query = query.Where("id = ?", state.RoomID)
+	case models.EmergencyScopeBuilding:
+		query = query.Where("building_id = ?", state.BuildingID)
+	}
+
+	err := query.Find(&rooms).Error
+	return rooms, err
+}
+
+func (s *EmergencyService) logRooms(rooms []models.Room, eventType models.LogEventType, result models.AccessResult, actorID uint, at time.Time, description string) error {
+	for _, room := range rooms {
+		actor := actorID
+		entry := models.Log{
+			RoomID:       room.ID,
+			Timestamp:    at,
+			AccessResult: result,
+			EventType:    eventType,
+			ActorUserID:  &actor,
+			Description:  description,
+		}
+		if err := s.db.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify tells the readers of the affected rooms about the new state and
+// broadcasts it. Reader acknowledgements are not awaited, a reader that is
+// offline picks up the state from the server on its next check.
+func (s *EmergencyService) notify(state models.EmergencyState, rooms []models.Room, active bool) {
+	if !s.wsEnabled {
+		return
+	}
+
+	roomIDs := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	hub := s.wsHandler.GetHub()
+
+	var devices []models.Device
+	if len(roomIDs) > 0 {
+		if err := s.db.Where("room_id IN ? AND enabled = ?", roomIDs, true).Find(&devices).Error; err != nil {
+			log.Printf("Olvasók lekérdezése sikertelen a vészhelyzet értesítéshez: %v", err)
+		}
+	}
+
+	command := emergencyCommand(state, active)
+	for _, device := range devices {
+		go func(device models.Device) {
+			if _, _, err := hub.SendCommand(device.ID, command, doorCommandAckTimeout); err != nil && err != websocket.ErrReaderNotConnected {
+				log.Printf("Vészhelyzeti parancs küldése sikertelen (%s): %v", device.DeviceID, err)
+			}
+		}(device)
+	}
+
+	hub.BroadcastToAll("emergency", map[string]interface{}{
+		"id":                 state.ID,
+		"mode":               state.Mode,
+		"scope":              state.Scope,
+		"room_id":            state.RoomID,
+		"building_id":        state.BuildingID,
+		"building":           state.Building,
+		"responder_group_id": state.ResponderGroupID,
+		"reason":             state.Reason,
+		"active":             active,
+		"rooms":              roomIDs,
+		"timestamp":          time.Now().Format(time.RFC3339),
+	})
+}
+
+func emergencyCommand(state models.EmergencyState, active bool) websocket.ReaderCommand {
+	params := map[string]interface{}{
+		"emergency_id": state.ID,
+		"active":       active,
+	}
+
+	switch {
+	case state.Mode == models.EmergencyLockdown:
+		return websocket.ReaderCommand{Command: websocket.ReaderCommandLockdown, Params: params}
+	case active:
+		params["hold"] = true
+		return websocket.ReaderCommand{Command: websocket.ReaderCommandUnlock, Params: params}
+	default:
+		return websocket.ReaderCommand{Command: websocket.ReaderCommandLock, Params: params}
+	}
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+type GroupHierarchyService struct {
+	db *gorm.DB
+}
+
+type RoomSource struct {
+	GroupID         uint       `json:"group_id"`
+	GroupName       string     `json:"group_name"`
+	Depth           int        `json:"depth"`
+	Inherited       bool       `json:"inherited"`
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+	TimeRestriction string     `json:"time_restriction"`
+}
+
+type EffectiveRoom struct {
+	Room    models.Room  `json:"room"`
+	Sources []RoomSource `json:"sources"`
+}
+
+func NewGroupHierarchyService(db *gorm.DB) *GroupHierarchyService {
+	return &GroupHierarchyService{db: db}
+}
+
+func (s *GroupHierarchyService) loadGroups() (map[uint]models.Group, error) {
+	var groups []models.Group
+	if err := s.db.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Group, len(groups))
+	for _, group := range groups {
+		byID[group.ID] = group
+	}
+
+	return byID, nil
+}
+
+// chain returns the group followed by its ancestors, nearest first. The walk
+// stops at a missing (or deleted) parent and never visits a group twice.
+func chain(groups map[uint]models.Group, groupID uint) []models.Group {
+	var result []models.Group
+	visited := make(map[uint]bool)
+
+	current, ok := groups[groupID]
+	for ok && !visited[current.ID] {
+		visited[current.ID] = true
+		result = append(result, current)
+
+		if current.ParentID == nil {
+			break
+		}
+		current, ok = groups[*current.ParentID]
+	}
+
+	return result
+}
+
+func (s *GroupHierarchyService) Chain(groupID uint) ([]models.Group, error) {
+	groups, err := s.loadGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	return chain(groups, groupID), nil
+}
+
+func (s *GroupHierarchyService) WouldCreateCycle(groupID uint, parentID uint) (bool, error) {
+	if groupID == parentID {
+		return true, nil
+	}
+
+	groups, err := s.loadGroups()
+	if err != nil {
+		return false, err
+	}
+
+	for _, ancestor := range chain(groups, parentID) {
+		if ancestor.ID == groupID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UserGroupChains returns one chain per direct group membership of the user.
+func (s *GroupHierarchyService) UserGroupChains(userID uint) ([][]models.Group, error) {
+	var groupIDs []uint
+	if err := s.db.Table("user_groups").Where("user_id = ?", userID).Pluck("group_id", &groupIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+
+	groups, err := s.loadGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	var chains [][]models.Group
+	for _, groupID := range groupIDs {
+		if groupChain := chain(groups, groupID); len(groupChain) > 0 {
+			chains = append(chains, groupChain)
+		}
+	}
+
+	return chains, nil
+}
+
+func (s *GroupHierarchyService) UserGroupIDs(userID uint) ([]uint, error) {
+	chains, err := s.UserGroupChains(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	var ids []uint
+	for _, groupChain := range chains {
+		for _, group := range groupChain {
+			if !seen[group.ID] {
+				seen[group.ID] = true
+				ids = append(ids, group.ID)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *GroupHierarchyService) EffectiveRooms(groupID uint) ([]EffectiveRoom, error) {
+	groupChain, err := s.Chain(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var effective []EffectiveRoom
+	indexByRoom := make(map[uint]int)
+
+	for depth, group := range groupChain {
+		var groupRooms []models.GroupRoom
+		if err := s.db.Preload("Room").Where("group_id = ? AND active = ?", group.ID, true).Find(&groupRooms).Error; err != nil {
+			return nil, err
+		}
+
+		for _, groupRoom := range groupRooms {
+			source := RoomSource{
+				GroupID:         group.ID,
+				GroupName:       group.Name,
+				Depth:           depth,
+				Inherited:       depth > 0,
+				ValidFrom:       groupRoom.ValidFrom,
+				ValidUntil:      groupRoom.ValidUntil,
+				TimeRestriction: groupRoom.TimeRestriction,
+			}
+
+			if i, ok := indexByRoom[groupRoom.RoomID]; ok {
+				effective[i].Sources = append(effective[i].Sources, source)
+				continue
+			}
+
+			indexByRoom[groupRoom.RoomID] = len(effective)
+			effective = append(effective, EffectiveRoom{
+				Room:    groupRoom.Room,
+				Sources: []RoomSource{source},
+			})
+		}
+	}
+
+	return effective, nil
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"rfid/internal/models"
+)
+
+// ICalEvent is the part of a VEVENT the calendar exceptions need. Dates are
+// inclusive, Hours is set for timed events that start and end on one day.
+type ICalEvent struct {
+	UID       string
+	Summary   string
+	StartDate string
+	EndDate   string
+	Hours     string
+}
+
+// ParseICal reads the VEVENT entries of an iCalendar (RFC 5545) document,
+// converting timed events into loc. Recurrence rules are not expanded.
+func ParseICal(r io.Reader, loc *time.Location) ([]ICalEvent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ICalEvent
+	var props map[string]icalProperty
+
+	for _, line := range lines {
+		name, prop, ok := parseICalLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			props = make(map[string]icalProperty)
+		case name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			if props == nil {
+				continue
+			}
+			event, err := icalEvent(props, loc)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+			props = nil
+		case props != nil:
+			if _, exists := props[name]; !exists {
+				props[name] = prop
+			}
+		}
+	}
+
+	return events, nil
+}
+
+type icalProperty struct {
+	params map[string]string
+	value  string
+}
+
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+func parseICalLine(line string) (string, icalProperty, bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", icalProperty{}, false
+	}
+
+	parts := strings.Split(head, ";")
+	prop := icalProperty{params: make(map[string]string), value: value}
+	for _, param := range parts[1:] {
+		if key, val, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), prop, true
+}
+
+func icalEvent(props map[string]icalProperty, loc *time.Location) (ICalEvent, error) {
+	event := ICalEvent{
+		UID:     props["UID"].value,
+		Summary: unescapeICalText(props["SUMMARY"].value),
+	}
+
+	startProp, ok := props["DTSTART"]
+	if !ok {
+		return ICalEvent{}, fmt.Errorf("hiányzó DTSTART (UID: %q)", event.UID)
+	}
+
+	start, allDay, err := parseICalTime(startProp, loc)
+	if err != nil {
+		return ICalEvent{}, err
+	}
+
+	end := start
+	if endProp, ok := props["DTEND"]; ok {
+		if end, _, err = parseICalTime(endProp, loc); err != nil {
+			return ICalEvent{}, err
+		}
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+
+	event.StartDate = start.Format(models.CalendarDateLayout)
+
+	if allDay {
+		// DTEND of an all-day event is exclusive.
+		last := end.AddDate(0, 0, -1)
+		if last.Before(start) {
+			last = start
+		}
+		event.EndDate = last.Format(models.CalendarDateLayout)
+		return event, nil
+	}
+
+	event.EndDate = end.Format(models.CalendarDateLayout)
+	if event.EndDate == event.StartDate && end.After(start) {
+		event.Hours = start.Format("15:04") + "-" + end.Format("15:04")
+	}
+
+	return event, nil
+}
+
+// parseICalTime reads a DATE or DATE-TIME value. Floating times are taken
+// to be in loc, as are dates.
+func parseICalTime(prop icalProperty, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.value)
+
+	if prop.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("érvénytelen dátum: %q", value)
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("érvénytelen időpont: %q", value)
+		}
+		return t.In(loc), false, nil
+	}
+
+	source := loc
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if tz, err := LoadLocation(tzid); err == nil {
+			source = tz
+		}
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, source)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("érvénytelen időpont: %q", value)
+	}
+	return t.In(loc), false, nil
+}
+
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
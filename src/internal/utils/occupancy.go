@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"rfid/internal/models"
+)
+
+type OccupancyService struct {
+	db *gorm.DB
+}
+
+type RoomOccupancyStatus struct {
+	RoomID    uint                   `json:"room_id"`
+	Capacity  int                    `json:"capacity"`
+	Occupancy int                    `json:"occupancy"`
+	Available *int                   `json:"available"`
+	Occupants []models.RoomOccupancy `json:"occupants"`
+}
+
+func NewOccupancyService(db *gorm.DB) *OccupancyService {
+	return &OccupancyService{db: db}
+}
+
+func (s *OccupancyService) Count(roomID uint) (int, error) {
+	var count int64
+	if err := s.db.Model(&models.RoomOccupancy{}).Where("room_id = ?", roomID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+func (s *OccupancyService) IsInside(roomID uint, cardID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.RoomOccupancy{}).Where("room_id = ? AND card_id = ?", roomID, cardID).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// RecordEntry marks the card as inside the room and reports whether the
+// occupancy changed.
+func (s *OccupancyService) RecordEntry(roomID uint, card models.Card, at time.Time) (bool, error) {
+	entry := models.RoomOccupancy{
+		RoomID:    roomID,
+		CardID:    card.ID,
+		UserID:    card.UserID,
+		EnteredAt: at,
+	}
+
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// RecordExit removes the card from the room and reports whether the
+// occupancy changed.
+func (s *OccupancyService) RecordExit(roomID uint, cardID uint) (bool, error) {
+	result := s.db.Where("room_id = ? AND card_id = ?", roomID, cardID).Delete(&models.RoomOccupancy{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (s *OccupancyService) Reset(roomID uint) error {
+	return s.db.Where("room_id = ?", roomID).Delete(&models.RoomOccupancy{}).Error
+}
+
+func (s *OccupancyService) Status(room models.Room) (*RoomOccupancyStatus, error) {
+	var occupants []models.RoomOccupancy
+	if err := s.db.Preload("Card").Preload("Card.User").Where("room_id = ?", room.ID).Order("entered_at").Find(&occupants).Error; err != nil {
+		return nil, err
+	}
+
+	status := &RoomOccupancyStatus{
+		RoomID:    room.ID,
+		Capacity:  room.Capacity,
+		Occupancy: len(occupants),
+		Occupants: occupants,
+	}
+
+	if room.Capacity > 0 {
+		available := room.Capacity - len(occupants)
+		if available < 0 {
+			available = 0
+		}
+		status.Available = &available
+	}
+
+	return status, nil
+}
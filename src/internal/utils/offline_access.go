@@ -0,0 +1,518 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+const (
+	offlineListValidity     = 24 * time.Hour
+	offlineListKeptVersions = 20
+	offlineSwipeMaxSkew     = 5 * time.Minute
+	offlineCardHashScheme   = "sha256(device_id + \":\" + card_id)"
+)
+
+type OfflineGrant struct {
+	Source     string     `json:"source"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Schedule   string     `json:"schedule,omitempty"`
+}
+
+type OfflineAccessEntry struct {
+	CardHash      string         `json:"card_hash"`
+	CardExpiresAt *time.Time     `json:"card_expires_at,omitempty"`
+	Grants        []OfflineGrant `json:"grants"`
+}
+
+type OfflineRoomRules struct {
+	AccessLevel  models.AccessLevel `json:"access_level"`
+	OpeningHours string             `json:"opening_hours,omitempty"`
+	Timezone     string             `json:"timezone"`
+	Exceptions   []OfflineException `json:"exceptions,omitempty"`
+
+	// RequiresPIN rooms cannot verify the PIN offline, readers deny them
+	// until the server is reachable again.
+	RequiresPIN bool `json:"requires_pin,omitempty"`
+
+	// DualAuthWindow is set for two-person rooms, a reader pairs the taps
+	// itself while offline.
+	DualAuthWindow int `json:"dual_authorization_window,omitempty"`
+}
+
+// OfflineException is a calendar exception falling into the validity of the
+// list, so a reader can honour holidays and extra openings while offline.
+type OfflineException struct {
+	Type      models.CalendarExceptionType `json:"type"`
+	Scope     models.CalendarScope         `json:"scope"`
+	StartDate string                       `json:"start_date"`
+	EndDate   string                       `json:"end_date"`
+	Hours     string                       `json:"hours,omitempty"`
+}
+
+// OfflineAccessList is the signed payload sent to a reader. A delta carries
+// the base version it applies to, the added or changed entries and the
+// hashes of the removed ones.
+type OfflineAccessList struct {
+	DeviceID    string               `json:"device_id"`
+	RoomID      uint                 `json:"room_id"`
+	Version     int                  `json:"version"`
+	BaseVersion *int                 `json:"base_version,omitempty"`
+	Full        bool                 `json:"full"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	ValidUntil  time.Time            `json:"valid_until"`
+	HashScheme  string               `json:"hash_scheme"`
+	Room        OfflineRoomRules     `json:"room"`
+	Entries     []OfflineAccessEntry `json:"entries"`
+	Removed     []string             `json:"removed,omitempty"`
+}
+
+// SignedAccessList carries the payload as the exact bytes that were signed.
+type SignedAccessList struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+	Algorithm string `json:"algorithm"`
+	Version   int    `json:"version"`
+}
+
+type OfflineSwipeRejection struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type OfflineSwipeResult struct {
+	Imported   int                     `json:"imported"`
+	Duplicates int                     `json:"duplicates"`
+	Rejected   []OfflineSwipeRejection `json:"rejected"`
+}
+
+type OfflineAccessService struct {
+	db         *gorm.DB
+	groups     *GroupHierarchyService
+	calendar   *CalendarService
+	timezones  *TimezoneService
+	privateKey ed25519.PrivateKey
+}
+
+// MinAccessListSigningKeyLength is the shortest signing key secret the
+// server starts with.
+const MinAccessListSigningKeyLength = 32
+
+// NewOfflineAccessService derives the signing key from secret, so every
+// server instance configured with the same secret signs with the same key.
+func NewOfflineAccessService(db *gorm.DB, secret string) *OfflineAccessService {
+	seed := sha256.Sum256([]byte("rfid-offline-access-list:" + secret))
+
+	return &OfflineAccessService{
+		db:         db,
+		groups:     NewGroupHierarchyService(db),
+		calendar:   NewCalendarService(db),
+		timezones:  NewTimezoneService(db),
+		privateKey: ed25519.NewKeyFromSeed(seed[:]),
+	}
+}
+
+func (s *OfflineAccessService) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.privateKey.Public().(ed25519.PublicKey))
+}
+
+func OfflineCardHash(deviceID string, cardID string) string {
+	sum := sha256.Sum256([]byte(deviceID + ":" + cardID))
+	return hex.EncodeToString(sum[:])
+}
+
+// AccessList returns the signed list of the device. When since names a
+// stored version the list is a delta against it, otherwise it is complete.
+func (s *OfflineAccessService) AccessList(device *models.Device, since int) (*SignedAccessList, error) {
+	var room models.Room
+	if err := s.db.First(&room, device.RoomID).Error; err != nil {
+		return nil, err
+	}
+
+	entries, err := s.buildEntries(device, room)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := s.timezones.RoomLocation(room)
+
+	rules := OfflineRoomRules{
+		AccessLevel:  room.AccessLevel,
+		OpeningHours: room.OpeningHours,
+		Timezone:     loc.String(),
+		RequiresPIN:  room.RequiresPIN,
+	}
+	if room.DualAuthorization {
+		rules.DualAuthWindow = int(room.DualAuthorizationTimeout().Seconds())
+	}
+
+	today := time.Now().In(loc)
+	exceptions, err := s.calendar.ExceptionsOn(room,
+		today.Format(models.CalendarDateLayout),
+		today.Add(offlineListValidity).Format(models.CalendarDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	for _, exception := range exceptions {
+		rules.Exceptions = append(rules.Exceptions, OfflineException{
+			Type:      exception.Type,
+			Scope:     exception.Scope,
+			StartDate: exception.StartDate,
+			EndDate:   exception.EndDate,
+			Hours:     exception.Hours,
+		})
+	}
+
+	current, err := s.storeVersion(device, rules, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	list := OfflineAccessList{
+		DeviceID:    device.DeviceID,
+		RoomID:      room.ID,
+		Version:     current.Version,
+		Full:        true,
+		GeneratedAt: now,
+		ValidUntil:  now.Add(offlineListValidity),
+		HashScheme:  offlineCardHashScheme,
+		Room:        rules,
+		Entries:     entries,
+	}
+
+	if since > 0 && since <= current.Version {
+		var base models.DeviceAccessList
+		err := s.db.Where("device_id = ? AND version = ?", device.ID, since).First(&base).Error
+		if err == nil {
+			var baseEntries []OfflineAccessEntry
+			if err := json.Unmarshal([]byte(base.Entries), &baseEntries); err != nil {
+				return nil, err
+			}
+
+			list.Full = false
+			list.BaseVersion = &since
+			list.Entries, list.Removed = diffEntries(baseEntries, entries)
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+	}
+
+	return s.sign(list)
+}
+
+func (s *OfflineAccessService) sign(list OfflineAccessList) (*SignedAccessList, error) {
+	payload, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedAccessList{
+		Payload:   string(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, payload)),
+		Algorithm: "ed25519",
+		Version:   list.Version,
+	}, nil
+}
+
+// storeVersion saves the entries as a new version when they differ from the
+// latest stored one.
+func (s *OfflineAccessService) storeVersion(device *models.Device, rules OfflineRoomRules, entries []OfflineAccessEntry) (*models.DeviceAccessList, error) {
+	encoded, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	encodedRules, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(append(encodedRules, encoded...))
+	checksum := hex.EncodeToString(sum[:])
+
+	var latest models.DeviceAccessList
+	err = s.db.Where("device_id = ?", device.ID).Order("version DESC").First(&latest).Error
+	if err == nil && latest.Checksum == checksum {
+		return &latest, nil
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	version := models.DeviceAccessList{
+		DeviceID: device.ID,
+		Version:  latest.Version + 1,
+		Checksum: checksum,
+		Entries:  string(encoded),
+	}
+
+	if err := s.db.Create(&version).Error; err != nil {
+		return nil, err
+	}
+
+	s.db.Where("device_id = ? AND version <= ?", device.ID, version.Version-offlineListKeptVersions).Delete(&models.DeviceAccessList{})
+
+	return &version, nil
+}
+
+func diffEntries(base []OfflineAccessEntry, current []OfflineAccessEntry) ([]OfflineAccessEntry, []string) {
+	baseByHash := make(map[string]string, len(base))
+	for _, entry := range base {
+		encoded, _ := json.Marshal(entry)
+		baseByHash[entry.CardHash] = string(encoded)
+	}
+
+	changed := []OfflineAccessEntry{}
+	seen := make(map[string]bool, len(current))
+	for _, entry := range current {
+		seen[entry.CardHash] = true
+		encoded, _ := json.Marshal(entry)
+		if previous, ok := baseByHash[entry.CardHash]; !ok || previous != string(encoded) {
+			changed = append(changed, entry)
+		}
+	}
+
+	var removed []string
+	for _, entry := range base {
+		if !seen[entry.CardHash] {
+			removed = append(removed, entry.CardHash)
+		}
+	}
+
+	return changed, removed
+}
+
+// buildEntries collects every active card with a grant for the device's room
+// that is valid now or later. Time windows and schedules are left to the
+// reader, so the list stays usable for the whole validity period.
+func (s *OfflineAccessService) buildEntries(device *models.Device, room models.Room) ([]OfflineAccessEntry, error) {
+	entries := []OfflineAccessEntry{}
+	if room.IsAccessibleWithoutCard() {
+		return entries, nil
+	}
+
+	now := time.Now()
+	cardGrants := make(map[uint][]OfflineGrant)
+	userGrants := make(map[uint][]OfflineGrant)
+
+	var permissions []models.Permission
+	if err := s.db.Where("room_id = ? AND active = ?", room.ID, true).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, perm := range permissions {
+		if perm.ValidUntil != nil && !perm.ValidUntil.After(now) {
+			continue
+		}
+
+		grant := OfflineGrant{
+			ValidFrom:  utcTime(&perm.ValidFrom),
+			ValidUntil: utcTime(perm.ValidUntil),
+			Schedule:   perm.TimeRestriction,
+		}
+
+		switch {
+		case perm.CardID != nil:
+			grant.Source = "card"
+			cardGrants[*perm.CardID] = append(cardGrants[*perm.CardID], grant)
+		case perm.UserID != nil:
+			grant.Source = "user"
+			userGrants[*perm.UserID] = append(userGrants[*perm.UserID], grant)
+		}
+	}
+
+	if err := s.collectGroupGrants(room.ID, now, userGrants); err != nil {
+		return nil, err
+	}
+
+	// PIN credentials are left out, a reader cannot check the PIN offline.
+	var cards []models.Card
+	if err := s.db.Where("status = ? AND credential_type <> ?", models.CardStatusActive, models.CredentialPIN).Find(&cards).Error; err != nil {
+		return nil, err
+	}
+
+	for _, card := range cards {
+		if card.ExpiryDate != nil && !card.ExpiryDate.After(now) {
+			continue
+		}
+
+		grants := append(append([]OfflineGrant{}, cardGrants[card.ID]...), userGrants[card.UserID]...)
+		if len(grants) == 0 {
+			continue
+		}
+
+		sortGrants(grants)
+		entries = append(entries, OfflineAccessEntry{
+			CardHash:      OfflineCardHash(device.DeviceID, card.CardID),
+			CardExpiresAt: utcTime(card.ExpiryDate),
+			Grants:        grants,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CardHash < entries[j].CardHash
+	})
+
+	return entries, nil
+}
+
+// collectGroupGrants adds the room grants of every group, and of its
+// ancestors, to the members of the group.
+func (s *OfflineAccessService) collectGroupGrants(roomID uint, now time.Time, userGrants map[uint][]OfflineGrant) error {
+	var groupRooms []models.GroupRoom
+	if err := s.db.Where("room_id = ? AND active = ?", roomID, true).Find(&groupRooms).Error; err != nil {
+		return err
+	}
+
+	grantsByGroup := make(map[uint]OfflineGrant)
+	for _, groupRoom := range groupRooms {
+		if groupRoom.ValidUntil != nil && !groupRoom.ValidUntil.After(now) {
+			continue
+		}
+
+		grantsByGroup[groupRoom.GroupID] = OfflineGrant{
+			Source:     fmt.Sprintf("group:%d", groupRoom.GroupID),
+			ValidFrom:  utcTime(groupRoom.ValidFrom),
+			ValidUntil: utcTime(groupRoom.ValidUntil),
+			Schedule:   groupRoom.TimeRestriction,
+		}
+	}
+
+	if len(grantsByGroup) == 0 {
+		return nil
+	}
+
+	groups, err := s.groups.loadGroups()
+	if err != nil {
+		return err
+	}
+
+	var memberships []struct {
+		UserID  uint
+		GroupID uint
+	}
+	if err := s.db.Table("user_groups").Select("user_id, group_id").Scan(&memberships).Error; err != nil {
+		return err
+	}
+
+	for _, membership := range memberships {
+		for _, group := range chain(groups, membership.GroupID) {
+			if grant, ok := grantsByGroup[group.ID]; ok {
+				userGrants[membership.UserID] = append(userGrants[membership.UserID], grant)
+			}
+		}
+	}
+
+	return nil
+}
+
+func sortGrants(grants []OfflineGrant) {
+	key := func(g OfflineGrant) string {
+		encoded, _ := json.Marshal(g)
+		return string(encoded)
+	}
+
+	sort.Slice(grants, func(i, j int) bool {
+		return key(grants[i]) < key(grants[j])
+	})
+}
+
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC().Truncate(time.Second)
+	return &utc
+}
+
+// ImportSwipes stores the swipes a reader buffered while offline as logs with
+// their original timestamps. Swipes already uploaded are skipped, so a reader
+// can safely retry an upload.
+func (s *OfflineAccessService) ImportSwipes(device *models.Device, swipes []models.OfflineSwipe) (*OfflineSwipeResult, error) {
+	result := &OfflineSwipeResult{Rejected: []OfflineSwipeRejection{}}
+	now := time.Now()
+
+	for i, swipe := range swipes {
+		reject := func(message string) {
+			result.Rejected = append(result.Rejected, OfflineSwipeRejection{Index: i, Error: message})
+		}
+
+		if swipe.CardID == "" || swipe.Timestamp.IsZero() {
+			reject("hiányzó kártya azonosító vagy időpont")
+			continue
+		}
+		if swipe.Timestamp.After(now.Add(offlineSwipeMaxSkew)) {
+			reject("az időpont a jövőben van")
+			continue
+		}
+		if !swipe.Direction.IsValid() {
+			reject("érvénytelen irány")
+			continue
+		}
+		if swipe.Direction != "" && device.Direction != "" && swipe.Direction != device.Direction {
+			reject("az irány nem egyezik az olvasó irányával")
+			continue
+		}
+
+		var card models.Card
+		if err := s.db.Where("card_id = ? AND credential_type <> ?", swipe.CardID, models.CredentialPIN).First(&card).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				reject("ismeretlen kártya")
+				continue
+			}
+			return nil, err
+		}
+
+		var existing int64
+		if err := s.db.Model(&models.Log{}).
+			Where("card_id = ? AND room_id = ? AND device_id = ? AND timestamp = ? AND offline = ?",
+				card.ID, device.RoomID, device.DeviceID, swipe.Timestamp.Local(), true).
+			Count(&existing).Error; err != nil {
+			return nil, err
+		}
+		if existing > 0 {
+			result.Duplicates++
+			continue
+		}
+
+		direction := swipe.Direction
+		if direction == "" {
+			direction = device.Direction
+		}
+
+		entry := models.Log{
+			CardID:       card.ID,
+			RoomID:       device.RoomID,
+			Timestamp:    swipe.Timestamp.Local(),
+			AccessResult: models.AccessGranted,
+			Direction:    direction,
+			DeviceID:     device.DeviceID,
+			Offline:      true,
+			Description:  fmt.Sprintf("Offline döntés, hozzáférési lista verzió: %d", swipe.ListVersion),
+		}
+		if !swipe.Granted {
+			entry.AccessResult = models.AccessDenied
+			entry.DenialReason = swipe.DenialReason
+			if entry.DenialReason == "" {
+				entry.DenialReason = models.DenialReasonNoPermission
+			}
+		}
+
+		if err := s.db.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+		result.Imported++
+	}
+
+	return result, nil
+}
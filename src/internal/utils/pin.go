@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+const (
+	maxPINAttempts     = 5
+	pinLockoutDuration = 15 * time.Minute
+)
+
+var ErrInvalidPIN = errors.New("a PIN kód 4-8 számjegyből állhat")
+
+func ValidatePIN(pin string) error {
+	if len(pin) < 4 || len(pin) > 8 {
+		return ErrInvalidPIN
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return ErrInvalidPIN
+		}
+	}
+	return nil
+}
+
+// PINService manages the second factor PINs of users and their lockout
+// after repeated wrong entries.
+type PINService struct {
+	db *gorm.DB
+}
+
+func NewPINService(db *gorm.DB) *PINService {
+	return &PINService{db: db}
+}
+
+// Set replaces the PIN of the user and lifts a running lockout.
+func (s *PINService) Set(userID uint, pin string) error {
+	if err := ValidatePIN(pin); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return s.update(userID, map[string]interface{}{
+		"pin_hash":            string(hash),
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    nil,
+	})
+}
+
+func (s *PINService) Clear(userID uint) error {
+	return s.update(userID, map[string]interface{}{
+		"pin_hash":            "",
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    nil,
+	})
+}
+
+func (s *PINService) Unlock(userID uint) error {
+	return s.update(userID, map[string]interface{}{
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    nil,
+	})
+}
+
+// RecordFailure counts a wrong PIN and locks the PIN once the limit is
+// reached. It reports whether this attempt started a lockout.
+func (s *PINService) RecordFailure(userID uint, at time.Time) (bool, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return false, err
+	}
+
+	attempts := user.FailedPINAttempts + 1
+	if attempts < maxPINAttempts {
+		return false, s.update(userID, map[string]interface{}{"failed_pin_attempts": attempts})
+	}
+
+	lockedUntil := at.Add(pinLockoutDuration)
+	return true, s.update(userID, map[string]interface{}{
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    &lockedUntil,
+	})
+}
+
+// update goes through a map so the password hook of User is not triggered.
+func (s *PINService) update(userID uint, fields map[string]interface{}) error {
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
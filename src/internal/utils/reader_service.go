@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/websocket"
+)
+
+// ReaderService answers the requests of readers connected over websocket.
+type ReaderService struct {
+	devices       *DeviceService
+	monitor       *DeviceMonitor
+	accessControl *AccessControlService
+	offline       *OfflineAccessService
+}
+
+func NewReaderService(db *gorm.DB, monitor *DeviceMonitor, offline *OfflineAccessService) *ReaderService {
+	return &ReaderService{
+		devices:       NewDeviceService(db),
+		monitor:       monitor,
+		accessControl: NewAccessControlService(db),
+		offline:       offline,
+	}
+}
+
+func (s *ReaderService) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	s.accessControl.SetWebSocketHandler(wsHandler)
+}
+
+func (s *ReaderService) ResolveReader(keyDeviceID *uint, deviceID string) (*models.Device, error) {
+	return s.devices.ResolveReader(keyDeviceID, deviceID)
+}
+
+func (s *ReaderService) Heartbeat(device *models.Device, heartbeat models.DeviceHeartbeat, ip string) error {
+	return s.monitor.Heartbeat(device, heartbeat, ip)
+}
+
+// CheckAccess re-reads the device on every swipe, so a reader disabled or
+// moved while connected is refused straight away.
+func (s *ReaderService) CheckAccess(device *models.Device, request websocket.ReaderAccessRequest) (*websocket.ReaderAccessDecision, error) {
+	current, err := s.devices.ResolveByID(device.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := AccessRequest{
+		CardID:         request.CardID,
+		CredentialType: request.CredentialType,
+		RoomID:         request.RoomID,
+		Direction:      request.Direction,
+		PIN:            request.PIN,
+	}
+	if err := s.devices.BindRequest(current, &req); err != nil {
+		return nil, err
+	}
+
+	granted, reason, err := s.accessControl.Authorize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &websocket.ReaderAccessDecision{
+		Granted:      granted,
+		RoomID:       req.RoomID,
+		Direction:    req.Direction,
+		DenialReason: reason,
+	}, nil
+}
+
+func (s *ReaderService) AccessList(device *models.Device, since int) (interface{}, error) {
+	return s.offline.AccessList(device, since)
+}
+
+func (s *ReaderService) UploadOfflineSwipes(device *models.Device, swipes []models.OfflineSwipe) (interface{}, error) {
+	return s.offline.ImportSwipes(device, swipes)
+}
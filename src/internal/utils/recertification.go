@@ -0,0 +1,554 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/websocket"
+)
+
+const recertificationInterval = time.Minute
+
+var (
+	ErrCampaignTarget     = errors.New("pontosan egy helyiséget vagy épületet kell megadni")
+	ErrCampaignDeadline   = errors.New("a határidőnek a jövőben kell lennie")
+	ErrCampaignClosed     = errors.New("a kampány már lezárult")
+	ErrReviewDecided      = errors.New("a jogosultságról már döntöttek")
+	ErrReviewOwnGrant     = errors.New("saját jogosultságot nem lehet felülvizsgálni")
+	ErrCampaignRoomAbsent = errors.New("a megadott helyiség vagy épület nem létezik")
+)
+
+type CampaignInput struct {
+	Name       string    `json:"name" binding:"required"`
+	RoomID     *uint     `json:"room_id"`
+	BuildingID *uint     `json:"building_id"`
+	Deadline   time.Time `json:"deadline" binding:"required"`
+}
+
+type ReviewerSummary struct {
+	UserID    uint   `json:"user_id"`
+	Name      string `json:"name"`
+	Confirmed int    `json:"confirmed"`
+	Revoked   int    `json:"revoked"`
+}
+
+// RecertificationReport sums up the decisions of a campaign. Expired counts
+// the grants deactivated at the deadline.
+type RecertificationReport struct {
+	Campaign  models.RecertificationCampaign `json:"campaign"`
+	Total     int                            `json:"total"`
+	Confirmed int                            `json:"confirmed"`
+	Revoked   int                            `json:"revoked"`
+	Expired   int                            `json:"expired"`
+	Pending   int                            `json:"pending"`
+	Reviewers []ReviewerSummary              `json:"reviewers"`
+	Items     []models.RecertificationItem   `json:"items"`
+}
+
+// RecertificationService runs access recertification campaigns. The
+// reviewers of a grant are the ones who may decide access requests for its
+// room, see AccessRequestService.
+type RecertificationService struct {
+	db        *gorm.DB
+	requests  *AccessRequestService
+	groups    *GroupHierarchyService
+	wsHandler *websocket.WebSocketHandler
+	wsEnabled bool
+}
+
+func NewRecertificationService(db *gorm.DB) *RecertificationService {
+	return &RecertificationService{
+		db:        db,
+		requests:  NewAccessRequestService(db),
+		groups:    NewGroupHierarchyService(db),
+		wsEnabled: false,
+	}
+}
+
+func (s *RecertificationService) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	s.wsHandler = wsHandler
+	s.wsEnabled = (wsHandler != nil)
+}
+
+// Launch snapshots the active card, user and group grants of the target
+// rooms as items to review. Grants of pooled visitor cards are left out,
+// visits revoke them on their own.
+func (s *RecertificationService) Launch(input CampaignInput, creator models.User) (*models.RecertificationCampaign, error) {
+	if (input.RoomID == nil) == (input.BuildingID == nil) {
+		return nil, ErrCampaignTarget
+	}
+
+	now := time.Now()
+	deadline := input.Deadline.Local()
+	if !deadline.After(now) {
+		return nil, ErrCampaignDeadline
+	}
+
+	scope := NewBuildingScope(creator)
+	var rooms []models.Room
+	if input.RoomID != nil {
+		var room models.Room
+		if err := s.db.First(&room, *input.RoomID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrCampaignRoomAbsent
+			}
+			return nil, err
+		}
+		if !scope.AllowsRoom(room) {
+			return nil, ErrBuildingOutOfScope
+		}
+		rooms = append(rooms, room)
+	} else {
+		var building models.Building
+		if err := s.db.First(&building, *input.BuildingID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrCampaignRoomAbsent
+			}
+			return nil, err
+		}
+		if !scope.AllowsBuilding(building.ID) {
+			return nil, ErrBuildingOutOfScope
+		}
+		if err := s.db.Where("building_id = ?", building.ID).Find(&rooms).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	roomIDs := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	campaign := models.RecertificationCampaign{
+		Name:       input.Name,
+		RoomID:     input.RoomID,
+		BuildingID: input.BuildingID,
+		Deadline:   deadline,
+		CreatedBy:  creator.ID,
+		Status:     models.CampaignOpen,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+
+		items, err := grantItems(tx, campaign.ID, roomIDs, now)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyReviewers(campaign, rooms)
+
+	return &campaign, nil
+}
+
+func grantItems(tx *gorm.DB, campaignID uint, roomIDs []uint, now time.Time) ([]models.RecertificationItem, error) {
+	var items []models.RecertificationItem
+
+	var permissions []models.Permission
+	err := tx.Preload("Card").Preload("Card.User").Preload("User").
+		Where("room_id IN ? AND active = ?", roomIDs, true).
+		Where("card_id IS NULL OR card_id NOT IN (?)", tx.Model(&models.Card{}).Select("id").Where("pooled = ?", true)).
+		Order("room_id, id").
+		Find(&permissions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, permission := range permissions {
+		if permission.ValidUntil != nil && now.After(*permission.ValidUntil) {
+			continue
+		}
+
+		permissionID := permission.ID
+		item := models.RecertificationItem{
+			CampaignID:   campaignID,
+			PermissionID: &permissionID,
+			RoomID:       permission.RoomID,
+			Decision:     models.ReviewPending,
+		}
+
+		if permission.CardID != nil {
+			userID := permission.Card.UserID
+			item.GrantType = models.GrantCard
+			item.CardID = permission.CardID
+			item.UserID = &userID
+			item.Subject = permission.Card.User.FullName() + " (" + permission.Card.CardID + ")"
+		} else {
+			item.GrantType = models.GrantUser
+			item.UserID = permission.UserID
+			item.Subject = permission.User.FullName()
+		}
+
+		items = append(items, item)
+	}
+
+	var groupRooms []models.GroupRoom
+	if err := tx.Where("room_id IN ? AND active = ?", roomIDs, true).Order("room_id, group_id").Find(&groupRooms).Error; err != nil {
+		return nil, err
+	}
+
+	for _, groupRoom := range groupRooms {
+		if groupRoom.ValidUntil != nil && now.After(*groupRoom.ValidUntil) {
+			continue
+		}
+
+		var group models.Group
+		if err := tx.First(&group, groupRoom.GroupID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
+			}
+			return nil, err
+		}
+
+		groupID := groupRoom.GroupID
+		items = append(items, models.RecertificationItem{
+			CampaignID:     campaignID,
+			GrantType:      models.GrantGroup,
+			GroupID:        &groupID,
+			GroupGrantedAt: groupRoom.GrantedAt,
+			RoomID:         groupRoom.RoomID,
+			Subject:        group.Name,
+			Decision:       models.ReviewPending,
+		})
+	}
+
+	return items, nil
+}
+
+// Reviewable restricts a query on recertification_items to the pending
+// items of open campaigns the user can review.
+func (s *RecertificationService) Reviewable(query *gorm.DB, user models.User) *gorm.DB {
+	query = query.Where("decision = ? AND campaign_id IN (?)", models.ReviewPending,
+		s.db.Model(&models.RecertificationCampaign{}).Select("id").Where("status = ?", models.CampaignOpen))
+	return s.requests.Decidable(query, user)
+}
+
+// ExcludeOwn leaves out the items of the user's own grants, including the
+// grants of their groups and of the ancestors of those groups.
+func (s *RecertificationService) ExcludeOwn(query *gorm.DB, user models.User) (*gorm.DB, error) {
+	query = query.Where("user_id IS NULL OR user_id <> ?", user.ID)
+
+	groupIDs, err := s.groups.UserGroupIDs(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(groupIDs) > 0 {
+		query = query.Where("group_id IS NULL OR group_id NOT IN ?", groupIDs)
+	}
+
+	return query, nil
+}
+
+// ownGrant reports whether the reviewer holds the grant of the item, in
+// person or through a group.
+func (s *RecertificationService) ownGrant(item models.RecertificationItem, reviewer models.User) (bool, error) {
+	if item.UserID != nil && *item.UserID == reviewer.ID {
+		return true, nil
+	}
+	if item.GroupID == nil {
+		return false, nil
+	}
+
+	groupIDs, err := s.groups.UserGroupIDs(reviewer.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range groupIDs {
+		if id == *item.GroupID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Review confirms the grant of the item or revokes it at once. Nobody
+// reviews their own grants.
+func (s *RecertificationService) Review(itemID uint, reviewer models.User, decision models.ReviewDecision, note string) (*models.RecertificationItem, error) {
+	var item models.RecertificationItem
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Room").First(&item, itemID).Error; err != nil {
+			return err
+		}
+
+		var campaign models.RecertificationCampaign
+		if err := tx.First(&campaign, item.CampaignID).Error; err != nil {
+			return err
+		}
+		if campaign.Status != models.CampaignOpen {
+			return ErrCampaignClosed
+		}
+		if item.Decision != models.ReviewPending {
+			return ErrReviewDecided
+		}
+		own, err := s.ownGrant(item, reviewer)
+		if err != nil {
+			return err
+		}
+		if own {
+			return ErrReviewOwnGrant
+		}
+
+		allowed, err := s.requests.CanDecide(reviewer, item.Room)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrNotApprover
+		}
+
+		now := time.Now()
+		if decision == models.ReviewRevoked {
+			if err := deactivateGrant(tx, item, &reviewer.ID, now); err != nil {
+				return err
+			}
+		}
+
+		item.Decision = decision
+		item.DecidedBy = &reviewer.ID
+		item.DecidedAt = &now
+		item.Note = note
+
+		return tx.Model(&item).Updates(map[string]interface{}{
+			"decision":   decision,
+			"decided_by": reviewer.ID,
+			"decided_at": now,
+			"note":       note,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// Complete closes the campaign and deactivates the grants nobody reviewed.
+func (s *RecertificationService) Complete(id uint) (*RecertificationReport, error) {
+	now := time.Now()
+
+	var campaign models.RecertificationCampaign
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&campaign, id).Error; err != nil {
+			return err
+		}
+		if campaign.Status != models.CampaignOpen {
+			return ErrCampaignClosed
+		}
+
+		var pending []models.RecertificationItem
+		if err := tx.Where("campaign_id = ? AND decision = ?", id, models.ReviewPending).Find(&pending).Error; err != nil {
+			return err
+		}
+
+		for _, item := range pending {
+			if err := deactivateGrant(tx, item, nil, now); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&models.RecertificationItem{}).
+			Where("campaign_id = ? AND decision = ?", id, models.ReviewPending).
+			Updates(map[string]interface{}{
+				"decision":   models.ReviewExpired,
+				"decided_at": now,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&campaign).Updates(map[string]interface{}{
+			"status":       models.CampaignCompleted,
+			"completed_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.Report(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.wsEnabled {
+		s.wsHandler.GetHub().BroadcastToAdmins("recertification_event", map[string]interface{}{
+			"action":      "completed",
+			"campaign_id": campaign.ID,
+			"name":        campaign.Name,
+			"total":       report.Total,
+			"confirmed":   report.Confirmed,
+			"revoked":     report.Revoked,
+			"expired":     report.Expired,
+		})
+	}
+
+	return report, nil
+}
+
+// CompleteDue completes the open campaigns past their deadline and returns
+// how many were completed.
+func (s *RecertificationService) CompleteDue(now time.Time) (int, error) {
+	var campaigns []models.RecertificationCampaign
+	if err := s.db.Where("status = ? AND deadline <= ?", models.CampaignOpen, now.Local()).Find(&campaigns).Error; err != nil {
+		return 0, err
+	}
+
+	for i, campaign := range campaigns {
+		if _, err := s.Complete(campaign.ID); err != nil {
+			return i, err
+		}
+	}
+
+	return len(campaigns), nil
+}
+
+// Run completes due campaigns until the process exits.
+func (s *RecertificationService) Run() {
+	ticker := time.NewTicker(recertificationInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.CompleteDue(time.Now()); err != nil {
+			log.Printf("Lejárt felülvizsgálati kampányok lezárása sikertelen: %v", err)
+		}
+	}
+}
+
+func (s *RecertificationService) Report(id uint) (*RecertificationReport, error) {
+	report := &RecertificationReport{}
+	if err := s.db.First(&report.Campaign, id).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Preload("Room").Where("campaign_id = ?", id).Order("room_id, id").Find(&report.Items).Error; err != nil {
+		return nil, err
+	}
+
+	reviewers := make(map[uint]*ReviewerSummary)
+	var order []uint
+	for _, item := range report.Items {
+		report.Total++
+		switch item.Decision {
+		case models.ReviewConfirmed:
+			report.Confirmed++
+		case models.ReviewRevoked:
+			report.Revoked++
+		case models.ReviewExpired:
+			report.Expired++
+		default:
+			report.Pending++
+		}
+
+		if item.DecidedBy == nil {
+			continue
+		}
+		summary, ok := reviewers[*item.DecidedBy]
+		if !ok {
+			summary = &ReviewerSummary{UserID: *item.DecidedBy}
+			var user models.User
+			if err := s.db.First(&user, *item.DecidedBy).Error; err == nil {
+				summary.Name = user.FullName()
+			}
+			reviewers[*item.DecidedBy] = summary
+			order = append(order, *item.DecidedBy)
+		}
+		if item.Decision == models.ReviewConfirmed {
+			summary.Confirmed++
+		} else {
+			summary.Revoked++
+		}
+	}
+
+	report.Reviewers = []ReviewerSummary{}
+	for _, userID := range order {
+		report.Reviewers = append(report.Reviewers, *reviewers[userID])
+	}
+
+	return report, nil
+}
+
+// deactivateGrant turns off the grant of the item. revokedBy is nil when
+// the deadline did it. A group grant issued again since the launch is a new
+// grant and is left alone.
+func deactivateGrant(tx *gorm.DB, item models.RecertificationItem, revokedBy *uint, at time.Time) error {
+	revoked := map[string]interface{}{
+		"active":     false,
+		"revoked_at": at,
+		"revoked_by": revokedBy,
+	}
+
+	if item.GrantType == models.GrantGroup {
+		var groupRoom models.GroupRoom
+		err := tx.Where("group_id = ? AND room_id = ?", *item.GroupID, item.RoomID).First(&groupRoom).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !sameInstant(groupRoom.GrantedAt, item.GroupGrantedAt) {
+			return nil
+		}
+
+		return tx.Model(&models.GroupRoom{}).
+			Where("group_id = ? AND room_id = ?", groupRoom.GroupID, groupRoom.RoomID).
+			Updates(revoked).Error
+	}
+
+	return tx.Model(&models.Permission{}).
+		Where("id = ?", *item.PermissionID).
+		Updates(revoked).Error
+}
+
+func sameInstant(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// notifyReviewers tells every reviewer of the target rooms that a campaign
+// is waiting for them.
+func (s *RecertificationService) notifyReviewers(campaign models.RecertificationCampaign, rooms []models.Room) {
+	if !s.wsEnabled {
+		return
+	}
+
+	event := map[string]interface{}{
+		"action":      "launched",
+		"campaign_id": campaign.ID,
+		"name":        campaign.Name,
+		"deadline":    campaign.Deadline.Format(time.RFC3339),
+	}
+
+	notified := make(map[uint]bool)
+	for _, room := range rooms {
+		approvers, err := s.requests.Approvers(room)
+		if err != nil {
+			continue
+		}
+		for _, approver := range approvers {
+			// Admins get the event below.
+			if !notified[approver.ID] && !approver.IsAdmin {
+				notified[approver.ID] = true
+				s.wsHandler.GetHub().BroadcastToUser(approver.ID, "recertification_event", event)
+			}
+		}
+	}
+
+	s.wsHandler.GetHub().BroadcastToAdmins("recertification_event", event)
+}
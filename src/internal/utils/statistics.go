@@ -6,16 +6,38 @@ import (
 	"gorm.io/gorm"
 )
 
+// StatisticsService queries the access logs. Log timestamps are stored in
+// the server zone and compared as text, so range bounds are converted to it.
 type StatisticsService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	timezones *TimezoneService
+	scope     *BuildingScope
 }
 
 func NewStatisticsService(db *gorm.DB) *StatisticsService {
 	return &StatisticsService{
-		db: db,
+		db:        db,
+		timezones: NewTimezoneService(db),
 	}
 }
 
+// Scoped returns a service whose queries only count the logs of rooms in
+// the scope.
+func (ss *StatisticsService) Scoped(scope *BuildingScope) *StatisticsService {
+	scoped := *ss
+	scoped.scope = scope
+	return &scoped
+}
+
+// Location is the zone statistics of the room are reported in, the server
+// default when no room is given.
+func (ss *StatisticsService) Location(roomID uint) *time.Location {
+	if roomID == 0 {
+		return ss.timezones.DefaultLocation()
+	}
+	return ss.timezones.RoomLocationByID(roomID)
+}
+
 type RoomUsageStats struct {
 	RoomID       uint    `json:"room_id"`
 	RoomName     string  `json:"room_name"`
@@ -46,12 +68,13 @@ func (ss *StatisticsService) GetRoomUsageStats(roomID uint, start, end time.Time
 			"COUNT(CASE WHEN logs.access_result = 'denied' THEN 1 END) as total_denials, "+
 			"CAST(COUNT(CASE WHEN logs.access_result = 'granted' THEN 1 END) AS FLOAT) / COUNT(*) * 100 as access_rate").
 		Joins("LEFT JOIN rooms ON logs.room_id = rooms.id").
-		Where("logs.timestamp BETWEEN ? AND ?", start, end).
+		Where("logs.timestamp BETWEEN ? AND ? AND logs.event_type = 'access'", start.Local(), end.Local()).
 		Group("logs.room_id, rooms.name")
 
 	if roomID > 0 {
 		query = query.Where("logs.room_id = ?", roomID)
 	}
+	query = ss.scope.RoomColumn(ss.db, query, "logs.room_id")
 
 	if err := query.Scan(&stats).Error; err != nil {
 		return nil, err
@@ -71,12 +94,13 @@ func (ss *StatisticsService) GetCardUsageStats(cardID uint, start, end time.Time
 			"MAX(logs.timestamp) as last_used").
 		Joins("LEFT JOIN cards ON logs.card_id = cards.id").
 		Joins("LEFT JOIN users ON cards.user_id = users.id").
-		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted'", start, end).
+		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted' AND logs.event_type = 'access'", start.Local(), end.Local()).
 		Group("logs.card_id, users.first_name, users.last_name")
 
 	if cardID > 0 {
 		query = query.Where("logs.card_id = ?", cardID)
 	}
+	query = ss.scope.RoomColumn(ss.db, query, "logs.room_id")
 
 	if err := query.Scan(&stats).Error; err != nil {
 		return nil, err
@@ -85,72 +109,78 @@ func (ss *StatisticsService) GetCardUsageStats(cardID uint, start, end time.Time
 	return stats, nil
 }
 
+// GetAccessTimeSeriesData counts the granted accesses per interval. Buckets
+// follow the wall clock of the room's zone, so days and weeks start at local
+// midnight even across DST changes.
 func (ss *StatisticsService) GetAccessTimeSeriesData(roomID uint, interval string, start, end time.Time) ([]TimeSeriesData, error) {
-	var data []TimeSeriesData
-
-	var timeFormat string
-	switch interval {
-	case "hour":
-		timeFormat = "2006-01-02 15:00:00"
-	case "day":
-		timeFormat = "2006-01-02 00:00:00"
-	case "week":
-		timeFormat = "2006-01-02"
-	case "month":
-		timeFormat = "2006-01-01 00:00:00"
-	default:
-		timeFormat = "2006-01-02 15:00:00"
-	}
-
 	query := ss.db.Table("logs").
-		Select("strftime(?, logs.timestamp) as timestamp_str, COUNT(*) as count", timeFormat).
-		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted'", start, end).
-		Group("timestamp_str").
-		Order("timestamp_str")
+		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted' AND logs.event_type = 'access'", start.Local(), end.Local()).
+		Order("logs.timestamp")
 
 	if roomID > 0 {
 		query = query.Where("logs.room_id = ?", roomID)
 	}
+	query = ss.scope.RoomColumn(ss.db, query, "logs.room_id")
 
-	type rawData struct {
-		TimestampStr string `gorm:"column:timestamp_str"`
-		Count        int    `gorm:"column:count"`
-	}
-
-	var rawResults []rawData
-	if err := query.Scan(&rawResults).Error; err != nil {
+	var timestamps []time.Time
+	if err := query.Pluck("logs.timestamp", &timestamps).Error; err != nil {
 		return nil, err
 	}
 
-	for _, r := range rawResults {
-		t, err := time.Parse(timeFormat, r.TimestampStr)
-		if err != nil {
+	loc := ss.Location(roomID)
+
+	data := []TimeSeriesData{}
+	for _, timestamp := range timestamps {
+		bucket := bucketStart(timestamp.In(loc), interval)
+
+		if n := len(data); n > 0 && data[n-1].Timestamp.Equal(bucket) {
+			data[n-1].Count++
 			continue
 		}
 
 		data = append(data, TimeSeriesData{
-			Timestamp: t,
-			Count:     r.Count,
+			Timestamp: bucket,
+			Count:     1,
 		})
 	}
 
 	return data, nil
 }
 
+func bucketStart(t time.Time, interval string) time.Time {
+	year, month, day := t.Date()
+
+	switch interval {
+	case "day":
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	default:
+		// Subtracting the wall clock minutes keeps the two hours of a DST
+		// fall back apart.
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	}
+}
+
 func (ss *StatisticsService) GetMostAccessedRooms(limit int, start, end time.Time) ([]RoomUsageStats, error) {
 	var stats []RoomUsageStats
 
-	if err := ss.db.Table("logs").
+	query := ss.db.Table("logs").
 		Select("logs.room_id, rooms.name as room_name, "+
 			"COUNT(*) as total_entries, "+
 			"0 as total_denials, "+
 			"100.0 as access_rate").
 		Joins("LEFT JOIN rooms ON logs.room_id = rooms.id").
-		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted'", start, end).
+		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted' AND logs.event_type = 'access'", start.Local(), end.Local()).
 		Group("logs.room_id, rooms.name").
 		Order("total_entries DESC").
-		Limit(limit).
-		Scan(&stats).Error; err != nil {
+		Limit(limit)
+	query = ss.scope.RoomColumn(ss.db, query, "logs.room_id")
+
+	if err := query.Scan(&stats).Error; err != nil {
 		return nil, err
 	}
 
@@ -168,17 +198,19 @@ func (ss *StatisticsService) GetMostActiveUsers(limit int, start, end time.Time)
 		TotalAccess int    `json:"total_access"`
 	}
 
-	if err := ss.db.Table("logs").
+	query := ss.db.Table("logs").
 		Select("users.id as user_id, "+
 			"CONCAT(users.first_name, ' ', users.last_name) as full_name, "+
 			"COUNT(*) as total_access").
 		Joins("LEFT JOIN cards ON logs.card_id = cards.id").
 		Joins("LEFT JOIN users ON cards.user_id = users.id").
-		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted'", start, end).
+		Where("logs.timestamp BETWEEN ? AND ? AND logs.access_result = 'granted' AND logs.event_type = 'access'", start.Local(), end.Local()).
 		Group("users.id, users.first_name, users.last_name").
 		Order("total_access DESC").
-		Limit(limit).
-		Scan(&results).Error; err != nil {
+		Limit(limit)
+	query = ss.scope.RoomColumn(ss.db, query, "logs.room_id")
+
+	if err := query.Scan(&results).Error; err != nil {
 		return nil, err
 	}
 
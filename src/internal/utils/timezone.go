@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+var locationCache sync.Map
+
+// LoadLocation is time.LoadLocation with the loaded zones cached, as access
+// checks resolve the zone of a room on every request.
+func LoadLocation(name string) (*time.Location, error) {
+	if cached, ok := locationCache.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// DefaultLocation resolves DEFAULT_TIMEZONE, the system zone when it is not
+// set.
+func DefaultLocation() (*time.Location, error) {
+	name := os.Getenv("DEFAULT_TIMEZONE")
+	if name == "" {
+		return time.Local, nil
+	}
+	return LoadLocation(name)
+}
+
+// TimezoneService resolves the zone a room is evaluated in: the room's own
+// zone, then its building's, then the server default (DEFAULT_TIMEZONE).
+type TimezoneService struct {
+	db         *gorm.DB
+	defaultLoc *time.Location
+}
+
+// NewTimezoneService falls back to the system zone when DEFAULT_TIMEZONE is
+// invalid, the server refuses to start with one anyway.
+func NewTimezoneService(db *gorm.DB) *TimezoneService {
+	defaultLoc, err := DefaultLocation()
+	if err != nil {
+		defaultLoc = time.Local
+	}
+
+	return &TimezoneService{
+		db:         db,
+		defaultLoc: defaultLoc,
+	}
+}
+
+func (s *TimezoneService) DefaultLocation() *time.Location {
+	return s.defaultLoc
+}
+
+func (s *TimezoneService) RoomLocation(room models.Room) *time.Location {
+	if room.Timezone != "" {
+		if loc, err := LoadLocation(room.Timezone); err == nil {
+			return loc
+		}
+		log.Printf("Figyelmeztetés: ismeretlen időzóna a(z) #%d helyiségnél: %s", room.ID, room.Timezone)
+	}
+
+	return s.BuildingLocation(room.BuildingID)
+}
+
+func (s *TimezoneService) RoomLocationByID(roomID uint) *time.Location {
+	var room models.Room
+	if err := s.db.First(&room, roomID).Error; err != nil {
+		return s.defaultLoc
+	}
+
+	return s.RoomLocation(room)
+}
+
+func (s *TimezoneService) BuildingLocation(buildingID *uint) *time.Location {
+	if buildingID == nil {
+		return s.defaultLoc
+	}
+
+	var building models.Building
+	if err := s.db.First(&building, *buildingID).Error; err != nil || building.Timezone == "" {
+		return s.defaultLoc
+	}
+
+	loc, err := LoadLocation(building.Timezone)
+	if err != nil {
+		log.Printf("Figyelmeztetés: ismeretlen időzóna a(z) %s épületnél: %s", building.Name, building.Timezone)
+		return s.defaultLoc
+	}
+
+	return loc
+}
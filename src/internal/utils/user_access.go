@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+)
+
+const (
+	AccessSourcePublic = "public"
+	AccessSourceUser   = "user"
+	AccessSourceCard   = "card"
+	AccessSourceGroup  = "group"
+)
+
+// UserAccessSource is one grant through which the user reaches a room.
+// ValidNow also honours the time restriction in the zone of the room.
+type UserAccessSource struct {
+	Type            string     `json:"type"`
+	PermissionID    *uint      `json:"permission_id,omitempty"`
+	CardID          *uint      `json:"card_id,omitempty"`
+	GroupID         *uint      `json:"group_id,omitempty"`
+	GroupName       string     `json:"group_name,omitempty"`
+	ValidFrom       *time.Time `json:"valid_from,omitempty"`
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`
+	TimeRestriction string     `json:"time_restriction,omitempty"`
+	ValidNow        bool       `json:"valid_now"`
+}
+
+// Covers reports whether the grant is in force for the whole of [from,
+// until). Time restrictions are not looked at.
+func (s UserAccessSource) Covers(from, until time.Time) bool {
+	if s.ValidFrom != nil && s.ValidFrom.After(from) {
+		return false
+	}
+	return s.ValidUntil == nil || !s.ValidUntil.Before(until)
+}
+
+type UserRoomAccess struct {
+	Room    models.Room        `json:"room"`
+	Sources []UserAccessSource `json:"sources"`
+}
+
+// UserAccessService lists the rooms a user can enter and why, for the self
+// service portal.
+type UserAccessService struct {
+	db        *gorm.DB
+	groups    *GroupHierarchyService
+	timezones *TimezoneService
+}
+
+func NewUserAccessService(db *gorm.DB) *UserAccessService {
+	return &UserAccessService{
+		db:        db,
+		groups:    NewGroupHierarchyService(db),
+		timezones: NewTimezoneService(db),
+	}
+}
+
+// EffectiveAccess collects the public rooms and the active grants of the
+// user, directly, through an active card or through a group. Expired grants
+// are left out, grants starting later are listed as not valid now.
+func (s *UserAccessService) EffectiveAccess(userID uint, at time.Time) ([]UserRoomAccess, error) {
+	rooms := make(map[uint]*UserRoomAccess)
+
+	add := func(room models.Room, source UserAccessSource) {
+		access, ok := rooms[room.ID]
+		if !ok {
+			access = &UserRoomAccess{Room: room}
+			rooms[room.ID] = access
+		}
+		access.Sources = append(access.Sources, source)
+	}
+
+	var public []models.Room
+	if err := s.db.Where("access_level = ?", models.AccessLevelPublic).Find(&public).Error; err != nil {
+		return nil, err
+	}
+	for _, room := range public {
+		add(room, UserAccessSource{Type: AccessSourcePublic, ValidNow: true})
+	}
+
+	var cards []models.Card
+	if err := s.db.Where("user_id = ?", userID).Find(&cards).Error; err != nil {
+		return nil, err
+	}
+	var cardIDs []uint
+	for i := range cards {
+		if cards[i].IsActive() {
+			cardIDs = append(cardIDs, cards[i].ID)
+		}
+	}
+
+	var permissions []models.Permission
+	query := s.db.Preload("Room").Where("active = ?", true)
+	if len(cardIDs) > 0 {
+		query = query.Where("user_id = ? OR card_id IN ?", userID, cardIDs)
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, permission := range permissions {
+		if permission.Room.ID == 0 || (permission.ValidUntil != nil && at.After(*permission.ValidUntil)) {
+			continue
+		}
+
+		permissionID := permission.ID
+		validFrom := permission.ValidFrom
+		source := UserAccessSource{
+			Type:            AccessSourceUser,
+			PermissionID:    &permissionID,
+			CardID:          permission.CardID,
+			ValidFrom:       &validFrom,
+			ValidUntil:      permission.ValidUntil,
+			TimeRestriction: permission.TimeRestriction,
+			ValidNow:        permission.IsValid(at.In(s.timezones.RoomLocation(permission.Room))),
+		}
+		if permission.CardID != nil {
+			source.Type = AccessSourceCard
+		}
+		add(permission.Room, source)
+	}
+
+	chains, err := s.groups.UserGroupChains(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	for _, groupChain := range chains {
+		for _, group := range groupChain {
+			// Memberships sharing an ancestor would list its grants twice.
+			if seen[group.ID] {
+				continue
+			}
+			seen[group.ID] = true
+
+			var groupRooms []models.GroupRoom
+			if err := s.db.Preload("Room").Where("group_id = ? AND active = ?", group.ID, true).Find(&groupRooms).Error; err != nil {
+				return nil, err
+			}
+
+			for _, groupRoom := range groupRooms {
+				if groupRoom.Room.ID == 0 || (groupRoom.ValidUntil != nil && at.After(*groupRoom.ValidUntil)) {
+					continue
+				}
+
+				groupID := group.ID
+				add(groupRoom.Room, UserAccessSource{
+					Type:            AccessSourceGroup,
+					GroupID:         &groupID,
+					GroupName:       group.Name,
+					ValidFrom:       groupRoom.ValidFrom,
+					ValidUntil:      groupRoom.ValidUntil,
+					TimeRestriction: groupRoom.TimeRestriction,
+					ValidNow:        groupRoom.IsValid(at.In(s.timezones.RoomLocation(groupRoom.Room))),
+				})
+			}
+		}
+	}
+
+	result := make([]UserRoomAccess, 0, len(rooms))
+	for _, access := range rooms {
+		result = append(result, *access)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Room.ID < result[j].Room.ID
+	})
+
+	return result, nil
+}
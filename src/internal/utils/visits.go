@@ -0,0 +1,383 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rfid/internal/models"
+	"rfid/internal/websocket"
+)
+
+const visitExpiryInterval = time.Minute
+
+var (
+	ErrVisitInvalidWindow = errors.New("a látogatás vége a kezdete után és a jövőben kell legyen")
+	ErrVisitHostMissing   = errors.New("a vendéglátó nem létezik")
+	ErrVisitRoomMissing   = errors.New("a megadott helyiség nem létezik")
+	ErrVisitorEmailTaken  = errors.New("az e-mail cím egy nem látogató felhasználóhoz tartozik")
+	ErrCardPoolEmpty      = errors.New("nincs szabad kártya a látogatói készletben")
+	ErrVisitNotOpen       = errors.New("a látogatás már lezárult")
+)
+
+type VisitorDetails struct {
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+	Email     string `json:"email"`
+}
+
+type VisitRegistration struct {
+	Visitor  VisitorDetails `json:"visitor" binding:"required"`
+	HostID   uint           `json:"host_id"`
+	Company  string         `json:"company"`
+	Purpose  string         `json:"purpose"`
+	StartsAt time.Time      `json:"starts_at" binding:"required"`
+	EndsAt   time.Time      `json:"ends_at" binding:"required"`
+	RoomIDs  []uint         `json:"room_ids"`
+}
+
+// VisitService pre-registers visitors, hands out cards from the pool and
+// takes them back when the visit ends or expires.
+type VisitService struct {
+	db        *gorm.DB
+	wsHandler *websocket.WebSocketHandler
+	wsEnabled bool
+}
+
+func NewVisitService(db *gorm.DB) *VisitService {
+	return &VisitService{
+		db:        db,
+		wsEnabled: false,
+	}
+}
+
+func (s *VisitService) SetWebSocketHandler(wsHandler *websocket.WebSocketHandler) {
+	s.wsHandler = wsHandler
+	s.wsEnabled = (wsHandler != nil)
+}
+
+// Register creates the visit with its visitor user, issues the first idle
+// pool card and grants it the requested rooms and every main entrance for
+// the visit window.
+func (s *VisitService) Register(input VisitRegistration, actorID uint) (*models.Visit, error) {
+	startsAt := input.StartsAt.Local()
+	endsAt := input.EndsAt.Local()
+	if !endsAt.After(startsAt) || !endsAt.After(time.Now()) {
+		return nil, ErrVisitInvalidWindow
+	}
+
+	var visit models.Visit
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var host models.User
+		if err := tx.Where("is_visitor = ?", false).First(&host, input.HostID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrVisitHostMissing
+			}
+			return err
+		}
+
+		rooms, err := visitRooms(tx, input.RoomIDs)
+		if err != nil {
+			return err
+		}
+
+		visitor, err := visitorUser(tx, input.Visitor)
+		if err != nil {
+			return err
+		}
+
+		var card models.Card
+		if err := tx.Where("pooled = ? AND status = ? AND user_id = 0", true, models.CardStatusPending).Order("id").First(&card).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCardPoolEmpty
+			}
+			return err
+		}
+
+		err = tx.Model(&card).Updates(map[string]interface{}{
+			"user_id":     visitor.ID,
+			"status":      models.CardStatusActive,
+			"expiry_date": endsAt,
+			"issue_date":  time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, room := range rooms {
+			cardID := card.ID
+			validUntil := endsAt
+			permission := models.Permission{
+				CardID:     &cardID,
+				RoomID:     room.ID,
+				GrantedBy:  host.ID,
+				ValidFrom:  startsAt,
+				ValidUntil: &validUntil,
+				Active:     true,
+			}
+			if err := tx.Create(&permission).Error; err != nil {
+				return err
+			}
+		}
+
+		visit = models.Visit{
+			VisitorID: visitor.ID,
+			HostID:    host.ID,
+			CardID:    &card.ID,
+			Company:   input.Company,
+			Purpose:   input.Purpose,
+			StartsAt:  startsAt,
+			EndsAt:    endsAt,
+			Status:    models.VisitScheduled,
+			CreatedBy: actorID,
+			Rooms:     rooms,
+		}
+		return tx.Create(&visit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &visit, nil
+}
+
+// AddPoolCard registers an idle card for the visitor pool.
+func (s *VisitService) AddPoolCard(value string, label string) (*models.Card, error) {
+	encryptedCardID, err := EncryptCardID(value)
+	if err != nil {
+		return nil, err
+	}
+
+	card := models.Card{
+		CredentialType:  models.CredentialCard,
+		Label:           label,
+		CardID:          value,
+		EncryptedCardID: encryptedCardID,
+		Status:          models.CardStatusPending,
+		IssueDate:       time.Now(),
+		Pooled:          true,
+	}
+	if err := s.db.Create(&card).Error; err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// End closes an open visit early. A visitor who never arrived has the
+// visit cancelled instead of ended.
+func (s *VisitService) End(id uint) (*models.Visit, error) {
+	var visit models.Visit
+	if err := s.db.First(&visit, id).Error; err != nil {
+		return nil, err
+	}
+	if !visit.IsOpen() {
+		return nil, ErrVisitNotOpen
+	}
+
+	status := models.VisitEnded
+	if visit.Status == models.VisitScheduled {
+		status = models.VisitCancelled
+	}
+
+	if err := s.close(&visit, status); err != nil {
+		return nil, err
+	}
+	return &visit, nil
+}
+
+// ExpireDue ends the open visits whose window is over and returns how many
+// cards went back to the pool.
+func (s *VisitService) ExpireDue(now time.Time) (int, error) {
+	var visits []models.Visit
+	if err := s.db.Where("status IN ? AND ends_at <= ?", []models.VisitStatus{models.VisitScheduled, models.VisitCheckedIn}, now.Local()).Find(&visits).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range visits {
+		if err := s.close(&visits[i], models.VisitEnded); err != nil {
+			return i, err
+		}
+	}
+
+	return len(visits), nil
+}
+
+// Run expires visits until the process exits.
+func (s *VisitService) Run() {
+	ticker := time.NewTicker(visitExpiryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.ExpireDue(time.Now()); err != nil {
+			log.Printf("Lejárt látogatások lezárása sikertelen: %v", err)
+		}
+	}
+}
+
+// close returns the card of the visit to the pool. The visit permissions
+// are removed, so the next visitor starts with a clean card.
+func (s *VisitService) close(visit *models.Visit, status models.VisitStatus) error {
+	now := time.Now()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if visit.CardID != nil {
+			if err := tx.Where("card_id = ?", *visit.CardID).Delete(&models.Permission{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("card_id = ?", *visit.CardID).Delete(&models.RoomOccupancy{}).Error; err != nil {
+				return err
+			}
+			err := tx.Model(&models.Card{}).Where("id = ?", *visit.CardID).Updates(map[string]interface{}{
+				"user_id":     0,
+				"status":      models.CardStatusPending,
+				"expiry_date": nil,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		visit.Status = status
+		visit.EndedAt = &now
+		return tx.Model(visit).Updates(map[string]interface{}{
+			"status":   status,
+			"ended_at": now,
+		}).Error
+	})
+}
+
+// NotifyArrival checks the visitor in on a granted entry at a main entrance
+// and tells the host.
+func (s *VisitService) NotifyArrival(card models.Card, room models.Room, at time.Time) {
+	var visit models.Visit
+	err := s.db.Preload("Visitor").
+		Where("card_id = ? AND status IN ?", card.ID, []models.VisitStatus{models.VisitScheduled, models.VisitCheckedIn}).
+		First(&visit).Error
+	if err != nil {
+		return
+	}
+
+	if visit.Status == models.VisitScheduled {
+		s.db.Model(&visit).Updates(map[string]interface{}{
+			"status":        models.VisitCheckedIn,
+			"checked_in_at": at,
+		})
+	}
+
+	if !s.wsEnabled {
+		return
+	}
+
+	s.wsHandler.GetHub().BroadcastToUser(visit.HostID, "visitor_arrived", map[string]interface{}{
+		"visit_id": visit.ID,
+		"visitor": map[string]interface{}{
+			"id":   visit.Visitor.ID,
+			"name": visit.Visitor.FullName(),
+		},
+		"company": visit.Company,
+		"room": map[string]interface{}{
+			"id":          room.ID,
+			"name":        room.Name,
+			"building":    room.Building,
+			"room_number": room.RoomNumber,
+		},
+		"timestamp": at.Format(time.RFC3339),
+	})
+}
+
+func visitRooms(tx *gorm.DB, roomIDs []uint) ([]models.Room, error) {
+	var rooms []models.Room
+	if len(roomIDs) > 0 {
+		if err := tx.Where("id IN ?", roomIDs).Find(&rooms).Error; err != nil {
+			return nil, err
+		}
+		if len(rooms) != len(uniqueIDs(roomIDs)) {
+			return nil, ErrVisitRoomMissing
+		}
+	}
+
+	var entrances []models.Room
+	if err := tx.Where("is_main_entrance = ?", true).Find(&entrances).Error; err != nil {
+		return nil, err
+	}
+
+	for _, entrance := range entrances {
+		listed := false
+		for _, room := range rooms {
+			if room.ID == entrance.ID {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			rooms = append(rooms, entrance)
+		}
+	}
+
+	return rooms, nil
+}
+
+// visitorUser reuses the visitor user of an earlier visit with the same
+// e-mail address. Visitor users get a random password, they cannot log in.
+func visitorUser(tx *gorm.DB, details VisitorDetails) (*models.User, error) {
+	if details.Email != "" {
+		var existing models.User
+		err := tx.Where("email = ?", details.Email).First(&existing).Error
+		if err == nil {
+			if !existing.IsVisitor {
+				return nil, ErrVisitorEmailTaken
+			}
+			return &existing, nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+	}
+
+	suffix, err := randomHex(6)
+	if err != nil {
+		return nil, err
+	}
+	password, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username:  "visitor-" + suffix,
+		Password:  password,
+		FirstName: details.FirstName,
+		LastName:  details.LastName,
+		Email:     details.Email,
+		IsVisitor: true,
+		Active:    true,
+	}
+	if user.Email == "" {
+		user.Email = user.Username + "@visitor.local"
+	}
+
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,8 +16,9 @@ import (
 )
 
 type WebSocketHandler struct {
-	db  *gorm.DB
-	hub *Hub
+	db      *gorm.DB
+	hub     *Hub
+	readers ReaderBackend
 }
 
 func NewWebSocketHandler(db *gorm.DB) *WebSocketHandler {
@@ -38,12 +40,20 @@ var upgrader = websocket.Upgrader{
 }
 
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
+	if isReaderRequest(c) {
+		h.handleReaderWebSocket(c)
+		return
+	}
+
 	var userID uint
 	var isAdmin bool
 
 	tokenString := c.Query("token")
 	if tokenString != "" {
 		jwtSecret := os.Getenv("JWT_SECRET")
+		if jwtSecret == "" {
+			jwtSecret = "default-rfid-jwt-secret-change-me-in-production"
+		}
 
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -55,10 +65,10 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 
 		if err == nil && token.Valid {
 			if claims, ok := token.Claims.(jwt.MapClaims); ok {
-				if id, ok := claims["user_id"].(float64); ok {
+				if id, ok := claims["id"].(float64); ok {
 					userID = uint(id)
 				}
-				if admin, ok := claims["is_admin"].(bool); ok {
+				if admin, ok := claims["isAdmin"].(bool); ok {
 					isAdmin = admin
 				}
 			}
@@ -82,23 +92,23 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	go client.HandleClientConnection()
 }
 
-func (h *WebSocketHandler) NotifyAccessEvent(cardID uint, roomID uint, result string, reason string) {
+func (h *WebSocketHandler) NotifyAccessEvent(entry models.Log) {
 	var card models.Card
-	if err := h.db.Preload("User").First(&card, cardID).Error; err != nil {
+	if err := h.db.Preload("User").First(&card, entry.CardID).Error; err != nil {
 		log.Printf("Kártya adatok lekérése sikertelen: %v", err)
 		return
 	}
 
 	var room models.Room
-	if err := h.db.First(&room, roomID).Error; err != nil {
+	if err := h.db.First(&room, entry.RoomID).Error; err != nil {
 		log.Printf("Helyiség adatok lekérése sikertelen: %v", err)
 		return
 	}
 
 	event := map[string]interface{}{
 		"timestamp": map[string]interface{}{
-			"unix": card.LastUsed.Unix(),
-			"iso":  card.LastUsed.Format("2006-01-02T15:04:05Z07:00"),
+			"unix": entry.Timestamp.Unix(),
+			"iso":  entry.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
 		},
 		"card": map[string]interface{}{
 			"id":     card.ID,
@@ -111,8 +121,13 @@ func (h *WebSocketHandler) NotifyAccessEvent(cardID uint, roomID uint, result st
 			"building":    room.Building,
 			"room_number": room.RoomNumber,
 		},
-		"result": result,
-		"reason": reason,
+		"result":    entry.AccessResult,
+		"reason":    entry.DenialReason,
+		"direction": entry.Direction,
+	}
+
+	if entry.Description != "" {
+		event["description"] = entry.Description
 	}
 
 	if card.UserID > 0 {
@@ -126,6 +141,25 @@ func (h *WebSocketHandler) NotifyAccessEvent(cardID uint, roomID uint, result st
 	h.hub.SendAccessEvent(event)
 }
 
+func (h *WebSocketHandler) NotifyOccupancyChange(room models.Room, occupancy int, card models.Card, direction models.AccessDirection) {
+	event := map[string]interface{}{
+		"room": map[string]interface{}{
+			"id":          room.ID,
+			"name":        room.Name,
+			"building":    room.Building,
+			"room_number": room.RoomNumber,
+		},
+		"capacity":  room.Capacity,
+		"occupancy": occupancy,
+		"card_id":   card.ID,
+		"user_id":   card.UserID,
+		"direction": direction,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+
+	h.hub.BroadcastToAdmins("occupancy_update", event)
+}
+
 func (h *WebSocketHandler) NotifyCardExpiration(card models.Card) {
 	if card.UserID == 0 {
 		return
@@ -11,6 +11,7 @@ import (
 
 type Message struct {
 	Type    string      `json:"type"`
+	ID      string      `json:"id,omitempty"`
 	Content interface{} `json:"content"`
 	UserID  uint        `json:"user_id,omitempty"`
 	Admin   bool        `json:"admin,omitempty"`
@@ -22,6 +23,8 @@ type Client struct {
 	send      chan []byte
 	userID    uint
 	isAdmin   bool
+	deviceID  uint
+	onMessage func([]byte)
 	mu        sync.Mutex
 	isClosing bool
 }
@@ -32,6 +35,9 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	commands   map[string]*pendingCommand
+	commandsMu sync.Mutex
 }
 
 func NewHub() *Hub {
@@ -40,6 +46,7 @@ func NewHub() *Hub {
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
+		commands:   make(map[string]*pendingCommand),
 	}
 }
 
@@ -179,6 +186,28 @@ func (h *Hub) SendAccessEvent(event map[string]interface{}) {
 	}
 }
 
+// Reply sends a message to this client only, echoing the correlation ID of
+// the request it answers.
+func (client *Client) Reply(messageType string, id string, content interface{}) {
+	data, err := json.Marshal(Message{Type: messageType, ID: id, Content: content})
+	if err != nil {
+		log.Printf("Hiba a WebSocket üzenet készítése közben: %v", err)
+		return
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.isClosing {
+		return
+	}
+
+	select {
+	case client.send <- data:
+	default:
+	}
+}
+
 func (client *Client) HandleClientConnection() {
 	client.hub.register <- client
 
@@ -212,13 +241,18 @@ func (client *Client) readPump() {
 	})
 
 	for {
-		_, _, err := client.conn.ReadMessage()
+		_, data, err := client.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket hiba: %v", err)
 			}
 			break
 		}
+
+		client.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		if client.onMessage != nil {
+			client.onMessage(data)
+		}
 	}
 }
 
@@ -248,10 +282,14 @@ func (client *Client) writePump() {
 			}
 			w.Write(message)
 
-			n := len(client.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-client.send)
+			// Readers get one message per frame, dashboard clients get the
+			// queued messages batched.
+			if client.deviceID == 0 {
+				n := len(client.send)
+				for i := 0; i < n; i++ {
+					w.Write([]byte{'\n'})
+					w.Write(<-client.send)
+				}
 			}
 
 			if err := w.Close(); err != nil {
@@ -0,0 +1,347 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rfid/internal/config"
+	"rfid/internal/models"
+)
+
+// Reader connections share /ws with the dashboard clients. A reader sends
+// typed requests carrying a correlation ID and receives the response with the
+// same ID:
+//
+//	-> {"type":"check_access","id":"42","payload":{"card_id":"CARD001","direction":"entry"}}
+//	<- {"type":"access_decision","id":"42","content":{"granted":true,...}}
+//
+// Commands pushed by the server carry their own ID, which the reader echoes
+// back in a command_ack message.
+const (
+	ReaderMessageHeartbeat   = "heartbeat"
+	ReaderMessageCheckAccess = "check_access"
+	ReaderMessageCommandAck  = "command_ack"
+	ReaderMessageAccessList  = "access_list"
+	ReaderMessageOfflineLogs = "offline_swipes"
+
+	ReaderCommandUnlock       = "unlock"
+	ReaderCommandLock         = "lock"
+	ReaderCommandLockdown     = "lockdown"
+	ReaderCommandRefreshCache = "refresh_cache"
+)
+
+var (
+	ErrReaderNotConnected = errors.New("az olvasó nem kapcsolódik")
+	ErrCommandTimeout     = errors.New("az olvasó nem igazolta vissza a parancsot")
+)
+
+// ReaderBackend serves the reader connections. It is implemented in the
+// utils package, which already depends on this one.
+type ReaderBackend interface {
+	ResolveReader(keyDeviceID *uint, deviceID string) (*models.Device, error)
+	Heartbeat(device *models.Device, heartbeat models.DeviceHeartbeat, ip string) error
+	CheckAccess(device *models.Device, request ReaderAccessRequest) (*ReaderAccessDecision, error)
+	AccessList(device *models.Device, since int) (interface{}, error)
+	UploadOfflineSwipes(device *models.Device, swipes []models.OfflineSwipe) (interface{}, error)
+}
+
+type ReaderAccessRequest struct {
+	CardID         string                 `json:"card_id"`
+	CredentialType models.CredentialType  `json:"credential_type"`
+	PIN            string                 `json:"pin,omitempty"`
+	RoomID         uint                   `json:"room_id"`
+	Direction      models.AccessDirection `json:"direction"`
+}
+
+type ReaderAccessDecision struct {
+	Granted      bool                   `json:"granted"`
+	RoomID       uint                   `json:"room_id"`
+	Direction    models.AccessDirection `json:"direction,omitempty"`
+	DenialReason models.DenialReason    `json:"reason_code,omitempty"`
+}
+
+type ReaderCommand struct {
+	Command string                 `json:"command"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+type CommandAck struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+type readerMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (h *WebSocketHandler) SetReaderBackend(backend ReaderBackend) {
+	h.readers = backend
+}
+
+func isReaderRequest(c *gin.Context) bool {
+	_, hasKeyDevice := c.Get("apiKeyDeviceID")
+	return hasKeyDevice || c.Query("device_id") != ""
+}
+
+// handleReaderWebSocket upgrades a reader connection. The reader is
+// identified by its device scoped API key, or by the device_id query
+// parameter when API keys are not required. Integration keys are not bound
+// to a reader and cannot connect as one.
+func (h *WebSocketHandler) handleReaderWebSocket(c *gin.Context) {
+	if h.readers == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Olvasó kapcsolatok nem támogatottak"})
+		return
+	}
+
+	var keyDeviceID *uint
+	if value, exists := c.Get("apiKeyDeviceID"); exists {
+		if id, ok := value.(uint); ok {
+			keyDeviceID = &id
+		}
+	}
+
+	if value, exists := c.Get("config"); exists {
+		_, hasKey := c.Get("apiKeyID")
+		if cfg, ok := value.(*config.Config); ok && cfg.APIKeyRequired {
+			if !hasKey {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "API kulcs szükséges"})
+				return
+			}
+			if keyDeviceID == nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Olvasó kapcsolathoz olvasóhoz kötött API kulcs szükséges"})
+				return
+			}
+		}
+	}
+
+	device, err := h.readers.ResolveReader(keyDeviceID, c.Query("device_id"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Az olvasó nem jogosult a kapcsolódásra: " + err.Error()})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Olvasó WebSocket kapcsolat létrehozása sikertelen: %v", err)
+		return
+	}
+
+	ip := c.ClientIP()
+	client := &Client{
+		hub:      h.hub,
+		conn:     conn,
+		send:     make(chan []byte, 256),
+		deviceID: device.ID,
+	}
+	client.onMessage = func(data []byte) {
+		h.handleReaderMessage(client, device, ip, data)
+	}
+
+	go client.HandleClientConnection()
+}
+
+func (h *WebSocketHandler) handleReaderMessage(client *Client, device *models.Device, ip string, data []byte) {
+	var message readerMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		client.Reply("error", "", map[string]interface{}{"error": "Érvénytelen üzenet"})
+		return
+	}
+
+	switch message.Type {
+	case ReaderMessageHeartbeat:
+		var heartbeat models.DeviceHeartbeat
+		if len(message.Payload) > 0 {
+			if err := json.Unmarshal(message.Payload, &heartbeat); err != nil {
+				client.Reply("error", message.ID, map[string]interface{}{"error": "Érvénytelen heartbeat üzenet"})
+				return
+			}
+		}
+
+		if err := h.readers.Heartbeat(device, heartbeat, ip); err != nil {
+			log.Printf("Olvasó heartbeat mentése sikertelen (%s): %v", device.DeviceID, err)
+			client.Reply("error", message.ID, map[string]interface{}{"error": "Heartbeat mentése sikertelen"})
+			return
+		}
+
+		client.Reply("heartbeat_ack", message.ID, map[string]interface{}{"device_id": device.DeviceID})
+
+	case ReaderMessageCheckAccess:
+		var request ReaderAccessRequest
+		if err := json.Unmarshal(message.Payload, &request); err != nil || request.CardID == "" || message.ID == "" {
+			client.Reply("error", message.ID, map[string]interface{}{"error": "A check_access üzenethez azonosító és kártya azonosító szükséges"})
+			return
+		}
+
+		if !request.Direction.IsValid() {
+			client.Reply("error", message.ID, map[string]interface{}{"error": "Érvénytelen irány. Megengedett értékek: entry, exit"})
+			return
+		}
+
+		if request.CredentialType != "" && !request.CredentialType.IsValid() {
+			client.Reply("error", message.ID, map[string]interface{}{"error": "Érvénytelen azonosító típus. Megengedett értékek: card, mobile_nfc, pin"})
+			return
+		}
+
+		decision, err := h.readers.CheckAccess(device, request)
+		if err != nil {
+			client.Reply("error", message.ID, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		client.Reply("access_decision", message.ID, decision)
+
+	case ReaderMessageAccessList:
+		var request struct {
+			Since int `json:"since"`
+		}
+		if len(message.Payload) > 0 {
+			if err := json.Unmarshal(message.Payload, &request); err != nil {
+				client.Reply("error", message.ID, map[string]interface{}{"error": "Érvénytelen hozzáférési lista kérés"})
+				return
+			}
+		}
+
+		list, err := h.readers.AccessList(device, request.Since)
+		if err != nil {
+			client.Reply("error", message.ID, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		client.Reply("access_list", message.ID, list)
+
+	case ReaderMessageOfflineLogs:
+		var request struct {
+			Swipes []models.OfflineSwipe `json:"swipes"`
+		}
+		if err := json.Unmarshal(message.Payload, &request); err != nil {
+			client.Reply("error", message.ID, map[string]interface{}{"error": "Érvénytelen offline kártyahasználat üzenet"})
+			return
+		}
+
+		result, err := h.readers.UploadOfflineSwipes(device, request.Swipes)
+		if err != nil {
+			client.Reply("error", message.ID, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		client.Reply("offline_swipes_result", message.ID, result)
+
+	case ReaderMessageCommandAck:
+		var ack CommandAck
+		if len(message.Payload) > 0 {
+			if err := json.Unmarshal(message.Payload, &ack); err != nil {
+				client.Reply("error", message.ID, map[string]interface{}{"error": "Érvénytelen visszaigazolás"})
+				return
+			}
+		}
+
+		if !h.hub.resolveCommand(device.ID, message.ID, ack) {
+			log.Printf("Ismeretlen parancs visszaigazolás (%s): %s", device.DeviceID, message.ID)
+		}
+
+	default:
+		client.Reply("error", message.ID, map[string]interface{}{"error": "Ismeretlen üzenettípus: " + message.Type})
+	}
+}
+
+type pendingCommand struct {
+	deviceID uint
+	ack      chan CommandAck
+}
+
+func newCommandID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
+
+// IsReaderConnected reports whether the device has an open reader connection.
+func (h *Hub) IsReaderConnected(deviceID uint) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if client.deviceID == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// SendCommand pushes a command to every connection of the device and waits
+// for the first acknowledgement. A zero timeout does not wait.
+func (h *Hub) SendCommand(deviceID uint, command ReaderCommand, timeout time.Duration) (string, *CommandAck, error) {
+	id := newCommandID()
+
+	data, err := json.Marshal(Message{Type: "command", ID: id, Content: command})
+	if err != nil {
+		return "", nil, err
+	}
+
+	pending := &pendingCommand{deviceID: deviceID, ack: make(chan CommandAck, 1)}
+	h.commandsMu.Lock()
+	h.commands[id] = pending
+	h.commandsMu.Unlock()
+
+	defer func() {
+		h.commandsMu.Lock()
+		delete(h.commands, id)
+		h.commandsMu.Unlock()
+	}()
+
+	delivered := false
+	h.mu.RLock()
+	for client := range h.clients {
+		if client.deviceID != deviceID {
+			continue
+		}
+		select {
+		case client.send <- data:
+			delivered = true
+		default:
+		}
+	}
+	h.mu.RUnlock()
+
+	if !delivered {
+		return id, nil, ErrReaderNotConnected
+	}
+
+	if timeout <= 0 {
+		return id, nil, nil
+	}
+
+	select {
+	case ack := <-pending.ack:
+		return id, &ack, nil
+	case <-time.After(timeout):
+		return id, nil, ErrCommandTimeout
+	}
+}
+
+func (h *Hub) resolveCommand(deviceID uint, id string, ack CommandAck) bool {
+	h.commandsMu.Lock()
+	pending, ok := h.commands[id]
+	h.commandsMu.Unlock()
+
+	if !ok || pending.deviceID != deviceID {
+		return false
+	}
+
+	select {
+	case pending.ack <- ack:
+	default:
+	}
+	return true
+}
@@ -1338,12 +1338,8 @@ function showAddRoomForm() {
                 <input type="number" id="capacity" name="capacity" min="0">
             </div>
             <div class="form-group">
-                <label for="operating-hours">Nyitvatartási idő (pl. 08:00-18:00)</label>
-                <input type="text" id="operating-hours" name="operating-hours">
-            </div>
-            <div class="form-group">
-                <label for="operating-days">Nyitvatartási napok (0=vasárnap, 1=hétfő, ...)</label>
-                <input type="text" id="operating-days" name="operating-days" placeholder="pl. 1,2,3,4,5">
+                <label for="opening-hours">Nyitvatartás (üresen hagyva mindig nyitva)</label>
+                <input type="text" id="opening-hours" name="opening-hours" placeholder="pl. mon-fri 08:00-12:00,13:00-17:00; sat 22:00-06:00">
             </div>
         </form>
     `;
@@ -1356,8 +1352,7 @@ function showAddRoomForm() {
         const accessLevel = document.getElementById('access-level').value;
         const capacityEl = document.getElementById('capacity');
         const capacity = capacityEl.value ? parseInt(capacityEl.value) : 0;
-        const operatingHours = document.getElementById('operating-hours').value;
-        const operatingDays = document.getElementById('operating-days').value;
+        const openingHours = document.getElementById('opening-hours').value;
 
         if (!name) {
             alert('Kérjük, adja meg a helyiség nevét!');
@@ -1388,8 +1383,7 @@ function showAddRoomForm() {
                     room_number: roomNumber,
                     access_level: accessLevel,
                     capacity,
-                    operating_hours: operatingHours,
-                    operating_days: operatingDays
+                    opening_hours: openingHours
                 })
             });
 
@@ -1454,12 +1448,8 @@ async function showEditRoomForm(roomId) {
                     <input type="number" id="capacity" name="capacity" min="0" value="${room.capacity || 0}">
                 </div>
                 <div class="form-group">
-                    <label for="operating-hours">Nyitvatartási idő (pl. 08:00-18:00)</label>
-                    <input type="text" id="operating-hours" name="operating-hours" value="${room.operating_hours || ''}">
-                </div>
-                <div class="form-group">
-                    <label for="operating-days">Nyitvatartási napok (0=vasárnap, 1=hétfő, ...)</label>
-                    <input type="text" id="operating-days" name="operating-days" value="${room.operating_days || ''}" placeholder="pl. 1,2,3,4,5">
+                    <label for="opening-hours">Nyitvatartás</label>
+                    <input type="text" id="opening-hours" name="opening-hours" value="${room.opening_hours || ''}" placeholder="pl. mon-fri 08:00-12:00,13:00-17:00; sat 22:00-06:00">
                 </div>
             </form>
         `;
@@ -1472,8 +1462,7 @@ async function showEditRoomForm(roomId) {
             const accessLevel = document.getElementById('access-level').value;
             const capacityEl = document.getElementById('capacity');
             const capacity = capacityEl.value ? parseInt(capacityEl.value) : 0;
-            const operatingHours = document.getElementById('operating-hours').value;
-            const operatingDays = document.getElementById('operating-days').value;
+            const openingHours = document.getElementById('opening-hours').value;
 
             if (!name) {
                 alert('Kérjük, adja meg a helyiség nevét!');
@@ -1504,8 +1493,7 @@ async function showEditRoomForm(roomId) {
                         room_number: roomNumber,
                         access_level: accessLevel,
                         capacity,
-                        operating_hours: operatingHours,
-                        operating_days: operatingDays
+                        opening_hours: openingHours
                     })
                 });
 